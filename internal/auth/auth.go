@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/niccolot/BlogAggregator/internal/database"
 	"github.com/niccolot/BlogAggregator/internal/state"
@@ -29,15 +32,57 @@ func CheckPasswordHash(password string, hash string) error {
 	return nil
 }
 
+// PasswordEnv is read instead of prompting for passwords, for scripts
+const PasswordEnv = "GATOR_PASSWORD"
+
+// shared by every prompt, so that consecutive reads do not lose buffered lines
+var stdin = bufio.NewReader(os.Stdin)
+
+func ReadLine() (string, error) {
+	/*
+	* @brief reads the next line of stdin without the line ending,
+	* a last line without newline is returned as well
+	*/
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func readPassword(prompt string) ([]byte, error) {
+	/*
+	* @brief reads a password from the GATOR_PASSWORD env variable if set,
+	* otherwise from the terminal without echo or, when stdin is not a
+	* terminal (e.g. piped), from the next line of stdin
+	*/
+	if pass, ok := os.LookupEnv(PasswordEnv); ok {
+		return []byte(pass), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(line), nil
+	}
+
+	fmt.Println(prompt)
+
+	return term.ReadPassword(fd)
+}
+
 func AskNewPassword() (string, error) {
-	fmt.Println("Choose a password: ")
-	pass, err := term.ReadPassword(0)
+	pass, err := readPassword("Choose a password: ")
 	if err != nil {
 		return "", fmt.Errorf("failed to read password: %v", err)
 	}
 
-	fmt.Println("Repeat password: ")
-	pass2, err := term.ReadPassword(0)
+	pass2, err := readPassword("Repeat password: ")
 	if err != nil {
 		return "", fmt.Errorf("failed to read password: %v", err)
 	}
@@ -55,8 +100,7 @@ func AskNewPassword() (string, error) {
 }
 
 func AskPassword(user *database.User) error {
-	fmt.Println("Insert password: ")
-	pass, err := term.ReadPassword(0)
+	pass, err := readPassword("Insert password: ")
 	if err != nil {
 		return fmt.Errorf("failed to read password: %v", err)
 	}
@@ -70,7 +114,7 @@ func AskPassword(user *database.User) error {
 }
 
 func CheckSuperUser(s *state.State, user *database.User) error {
-	if user.ID != s.Cfg.SuperUserID {
+	if !user.IsSuperuser.Bool {
 		return fmt.Errorf("you must be superuser to run this command")
 	}
 
@@ -1,12 +1,15 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
 	"github.com/niccolot/BlogAggregator/internal/state"
 )
 
+var ErrCommandNotFound = errors.New("command not found")
+
 type Command struct {
 	CmdName string
 	Args []string
@@ -45,7 +48,19 @@ func (c *Commands) RegisterCmd(name string, f func(*state.State, Command) error)
 func (c *Commands) Run(s *state.State, cmd Command) error {
 	handler, ok := c.Handlers[cmd.CmdName]
 	if !ok {
-		return fmt.Errorf("command %s not found", cmd.CmdName)
+		return fmt.Errorf("%w: %s", ErrCommandNotFound, cmd.CmdName)
+	}
+
+	// '--output <format>' applies to this command only
+	format, args, errOutput := extractOutputFlag(cmd.Args)
+	if errOutput != nil {
+		return errOutput
+	}
+	if format != "" {
+		prevFormat := s.Output
+		s.Output = format
+		defer func() { s.Output = prevFormat }()
+		cmd.Args = args
 	}
 
 	errCmd := handler(s,cmd)
@@ -68,15 +83,31 @@ func (c *Commands) Init() {
 	c.RegisterCmd("users", handlerGetUsers)
 	c.RegisterCmd("aggregate", middlewareLoggedIn(handlerAggregate))
 	c.RegisterCmd("stopagg", handlerStopAgg)
+	c.RegisterCmd("daemon", handlerDaemon)
 	c.RegisterCmd("addfeed", middlewareLoggedIn(handlerAddFeed))
 	c.RegisterCmd("feeds", handlerFeeds)
+	c.RegisterCmd("disabledfeeds", handlerDisabledFeeds)
+	c.RegisterCmd("enablefeed", middlewareLoggedIn(handlerEnableFeed))
+	c.RegisterCmd("setinterval", middlewareLoggedIn(handlerSetInterval))
 	c.RegisterCmd("follow", middlewareLoggedIn(handlerFollow))
+	c.RegisterCmd("import", middlewareLoggedIn(handlerImport))
+	c.RegisterCmd("export", middlewareLoggedIn(handlerExport))
 	c.RegisterCmd("following", middlewareLoggedIn(handlerFollowing))
 	c.RegisterCmd("unfollow", middlewareLoggedIn(handlerUnfollow))
+	c.RegisterCmd("folder", middlewareLoggedIn(handlerFolder))
 	c.RegisterCmd("browse", middlewareLoggedIn(handlerBrowse))
-	c.RegisterCmd("open", handlerOpen)
+	c.RegisterCmd("search", middlewareLoggedIn(handlerSearch))
+	c.RegisterCmd("open", middlewareLoggedIn(handlerOpen))
+	c.RegisterCmd("markread", middlewareLoggedIn(handlerMarkRead))
+	c.RegisterCmd("markunread", middlewareLoggedIn(handlerMarkUnread))
 	c.RegisterCmd("changesuper", middlewareLoggedIn(handlerChangeSuperUser))
 	c.RegisterCmd("changepassword", middlewareLoggedIn(handlerChangePassword))
 	c.RegisterCmd("bookmark", middlewareLoggedIn(handlerBookmark))
+	c.RegisterCmd("bookmarks", middlewareLoggedIn(handlerBookmarks))
+	c.RegisterCmd("unbookmark", middlewareLoggedIn(handlerUnbookmark))
+	c.RegisterCmd("tag", middlewareLoggedIn(handlerTag))
+	c.RegisterCmd("untag", middlewareLoggedIn(handlerUntag))
+	c.RegisterCmd("note", middlewareLoggedIn(handlerNote))
+	c.RegisterCmd("output", handlerOutput)
 	c.RegisterCmd("help", handlerHelp)
 }
\ No newline at end of file
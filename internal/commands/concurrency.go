@@ -2,20 +2,95 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/niccolot/BlogAggregator/internal/database"
 	"github.com/niccolot/BlogAggregator/internal/rss"
+	"github.com/niccolot/BlogAggregator/internal/state"
 )
 
-func aggregate(pars *aggPars) error {
-	batchSize := int32(pars.numFeeds)
-	workers := pars.numFeeds
-	wg := sync.WaitGroup{}
-	
-	feedQueue, errScrape := rss.ScrapeFeeds(pars.s, context.Background(), batchSize)
+const (
+	defaultWorkers = 4
+	defaultFetchTimeout = 30 * time.Second
+)
+
+// any constant works, as long as every aggregator uses the same one
+const aggregatorLockKey = 0x67617472
+
+func runAggregation(ctx context.Context, pars *aggPars) error {
+	/*
+	* @brief every timeBetweenReqs up to 'numFeeds' due feeds are fetched
+	* by 'workers' goroutines, until ctx is cancelled. Only one aggregator at a time can run on a database
+	*/
+	politeness, errPoliteness := rss.PolitenessFromConfig(pars.s.Cfg)
+	if errPoliteness != nil {
+		return errPoliteness
+	}
+	rss.SetPoliteness(politeness)
+
+	release, errLock := lockAggregator(ctx, pars.s)
+	if errLock != nil {
+		return errLock
+	}
+	defer release()
+
+	pars.s.Aggregating = true
+	defer func() { pars.s.Aggregating = false }()
+
+	ticker := time.NewTicker(pars.timeBetweenReqs)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			aggregate(ctx, pars)
+		}
+	}
+}
+
+func lockAggregator(ctx context.Context, s *state.State) (release func(), err error) {
+	/*
+	* @brief takes a postgres advisory lock, so that two aggregators (e.g. the
+	* daemon and a REPL) do not fetch the same feeds. The lock belongs to the
+	* db session, so a dedicated connection is held until release is called
+	*/
+	conn, errConn := s.Conn.Conn(ctx)
+	if errConn != nil {
+		return nil, fmt.Errorf("failed to get database connection: %v", errConn)
+	}
+
+	q := database.New(conn)
+	locked, errLock := q.TryAdvisoryLock(ctx, aggregatorLockKey)
+	if errLock != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to lock aggregator: %v", errLock)
+	}
+
+	if !locked {
+		conn.Close()
+		return nil, fmt.Errorf("another aggregator is already running on this database")
+	}
+
+	release = func() {
+		// pooled connections are not closed, the lock must be released by hand
+		q.AdvisoryUnlock(context.Background(), aggregatorLockKey)
+		conn.Close()
+	}
+
+	return release, nil
+}
+
+func aggregate(ctx context.Context, pars *aggPars) error {
+	/*
+	* @brief fetches a batch of due feeds with a pool of workers fed
+	* by a channel, so that each feed is fetched once per cycle
+	*/
+	feeds, errScrape := rss.ScrapeFeeds(pars.s, ctx, int32(pars.numFeeds), pars.folderID)
 	if errScrape != nil {
 		if pars.logging {
 			log.Printf("Warning: error retrieving feeds: %v", errScrape)
@@ -23,64 +98,81 @@ func aggregate(pars *aggPars) error {
 		return errScrape
 	}
 
+	if len(feeds) == 0 {
+		return nil
+	}
+
+	feedQueue := make(chan database.Feed)
+	results := make(chan fetchResult)
+	wg := sync.WaitGroup{}
+
 	workerPars := &workerPars{
 		s: pars.s,
-		wg: &wg,
-		feedQueue: &feedQueue,
-		timeBetweenReqs: pars.timeBetweenReqs,
-		logging: pars.logging,
+		ctx: ctx,
+		fetchTimeout: pars.fetchTimeout,
 	}
 
-	for i := 0; i<workers; i++ {
+	workers := min(pars.workers, len(feeds))
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go workerFunc(i, workerPars)
+		go func(workerID int) {
+			defer wg.Done()
+			workerFunc(workerID, workerPars, feedQueue, results)
+		}(i)
 	}
 
-	wg.Wait()
+	go func() {
+		defer close(feedQueue)
+		for _, feed := range(feeds) {
+			select {
+			case feedQueue <- feed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	return nil
-}
+	// results is closed once every worker is done
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-func workerFunc(workerID int, pars *workerPars) {
-	queueMux := sync.Mutex{}
+	var fetched, failed int
+	for result := range(results) {
+		if result.err != nil {
+			failed++
+			if pars.logging {
+				log.Printf("[Worker %d] Timeout or failed to fetch feed '%s': %v", result.workerID, result.feed.Url, result.err)
+			}
+			continue
+		}
 
-	feedQueue := *pars.feedQueue
+		fetched++
+		if pars.logging {
+			log.Printf("[Worker %d] Succesfully fetched feed '%s' in %v", result.workerID, result.feed.Url, result.elapsed)
+		}
+	}
 
-	defer pars.wg.Done()
+	if pars.logging {
+		log.Printf("Cycle done: %d feeds fetched, %d failed", fetched, failed)
+	}
 
-	for {
-		queueMux.Lock()
-		var feed database.Feed
-		if len(feedQueue) > 0 {
-			feed = feedQueue[0]
-			feedQueue = feedQueue[1:]
-		}
-		queueMux.Unlock()
+	return nil
+}
 
-		// no more feeds in the queue
-		if feed.ID.String() == "" {
-			return
-		}
+func workerFunc(workerID int, pars *workerPars, feedQueue <-chan database.Feed, results chan<- fetchResult) {
+	for feed := range(feedQueue) {
+		// the timeout is per fetch, a slow feed does not starve the others.
+		// It starts once the host is free, waiting for it is not a failure
+		startTime := time.Now()
+		err := rss.FetchAndStoreFeed(pars.s, &feed, pars.ctx, pars.fetchTimeout)
 
-		ctxWithTimeout, cancel := context.WithTimeout(context.Background(), pars.timeBetweenReqs)
-		defer cancel()
-		
-		if feed.Url == "" {
-			return
-		}
-		
-		if pars.logging {
-			startTime := time.Now()
-			err := rss.FetchAndStoreFeed(pars.s, &feed, ctxWithTimeout)
-			
-			if err != nil {
-				log.Printf("[Worker %d] Timeout or failed to fetch feed '%s': %v", workerID, feed.Url, err)
-			} else {
-				log.Printf("[Worker %d] Succesfully fetched feed '%s' in %v", workerID, feed.Url, time.Since(startTime))
-			}
-		} else {
-			rss.FetchAndStoreFeed(pars.s, &feed, ctxWithTimeout)
+		results <- fetchResult{
+			workerID: workerID,
+			feed: feed,
+			err: err,
+			elapsed: time.Since(startTime),
 		}
-		
 	}
-}
\ No newline at end of file
+}
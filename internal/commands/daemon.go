@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/niccolot/BlogAggregator/internal/state"
+)
+
+const (
+	pidFileName = ".gator.pid"
+	daemonStopTimeout = 30 * time.Second
+)
+
+func handlerDaemon(s *state.State, cmd Command) error {
+	usage := "usage: daemon <time between reqs> [optional] -log --workers <n> --timeout <duration> [or] daemon status|stop"
+	flags, positional, errFlags := parseFlags(cmd.Args, nil, []string{"workers", "timeout"})
+	if errFlags != nil || len(positional) < 1 || len(positional) > 2 {
+		return errors.New(usage)
+	}
+
+	switch {
+	case positional[0] == "status" && len(cmd.Args) == 1:
+		return daemonStatus()
+	case positional[0] == "stop" && len(cmd.Args) == 1:
+		return daemonStop()
+	}
+
+	workers, fetchTimeout, errPool := parsePoolFlags(s, flags)
+	if errPool != nil {
+		return errPool
+	}
+
+	timeBetweenReqs, errParse := time.ParseDuration(positional[0])
+	if errParse != nil {
+		return errors.New(usage)
+	}
+
+	if timeBetweenReqs < time.Second {
+		timeBetweenReqs = time.Second
+		fmt.Println("Warning: time between request selected is too small, set to default 1s")
+	}
+
+	if len(positional) == 2 {
+		if positional[1] != "-log" {
+			return errors.New(usage)
+		}
+
+		logFile, err := setLogger("aggregation.log")
+		if err != nil {
+			return fmt.Errorf("failed to set logger: %v", err)
+		}
+
+		defer logFile.Close()
+
+		log.SetOutput(logFile)
+	}
+
+	// the daemon fetches every followed feed, not only the ones of a user
+	feeds, err := s.Db.GetFeeds(context.Background())
+	if err != nil {
+		return fmt.Errorf("error while retrieving feeds from database: %v", err)
+	}
+
+	if len(feeds) == 0 {
+		return fmt.Errorf("no feeds to aggregate")
+	}
+
+	removePidFile, err := writePidFile()
+	if err != nil {
+		return err
+	}
+	defer removePidFile()
+
+	// SIGINT/SIGTERM cancel the root context, in-flight fetches included
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pars := &aggPars{
+		s: s,
+		timeBetweenReqs: timeBetweenReqs,
+		numFeeds: len(feeds),
+		logging: true,
+		workers: workers,
+		fetchTimeout: fetchTimeout,
+	}
+
+	log.Printf("Daemon started (pid %d), collecting feeds every %s...\n", os.Getpid(), timeBetweenReqs)
+
+	errAgg := runAggregation(ctx, pars)
+	if errAgg != nil {
+		return errAgg
+	}
+
+	log.Println("Daemon stopped")
+
+	return nil
+}
+
+func daemonStatus() error {
+	pid, running, err := readPidFile()
+	if err != nil {
+		return err
+	}
+
+	if !running {
+		return daemonNotRunning(pid)
+	}
+
+	fmt.Printf("daemon running (pid %d)\n", pid)
+
+	return nil
+}
+
+func daemonStop() error {
+	pid, running, err := readPidFile()
+	if err != nil {
+		return err
+	}
+
+	if !running {
+		return daemonNotRunning(pid)
+	}
+
+	errKill := syscall.Kill(pid, syscall.SIGTERM)
+	if errKill != nil {
+		return fmt.Errorf("failed to stop daemon: %v", errKill)
+	}
+
+	// waits for the workers to be drained
+	deadline := time.Now().Add(daemonStopTimeout)
+	for isDaemon(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("daemon (pid %d) still running after %s", pid, daemonStopTimeout)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	fmt.Println("daemon stopped")
+
+	return nil
+}
+
+func daemonNotRunning(pid int) error {
+	if pid != 0 {
+		return fmt.Errorf("daemon not running (removed stale pid file of process %d)", pid)
+	}
+
+	return fmt.Errorf("daemon not running")
+}
+
+func getPidFile() (string, error) {
+	homeDir, errHome := os.UserHomeDir()
+	if errHome != nil {
+		return "", errHome
+	}
+
+	return filepath.Join(homeDir, pidFileName), nil
+}
+
+func readPidFile() (pid int, running bool, err error) {
+	/*
+	* @brief reads the daemon pid, a missing file or a file
+	* left behind by a dead process mean it is not running
+	*/
+	pidFile, err := getPidFile()
+	if err != nil {
+		return 0, false, err
+	}
+
+	content, errRead := os.ReadFile(pidFile)
+	if errors.Is(errRead, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if errRead != nil {
+		return 0, false, fmt.Errorf("failed to read pid file: %v", errRead)
+	}
+
+	pid, errConv := strconv.Atoi(strings.TrimSpace(string(content)))
+	if errConv != nil {
+		return 0, false, fmt.Errorf("invalid pid file '%s'", pidFile)
+	}
+
+	// left behind by a killed daemon, the pid may now belong to another process
+	if !isDaemon(pid) {
+		os.Remove(pidFile)
+		return pid, false, nil
+	}
+
+	return pid, true, nil
+}
+
+func writePidFile() (remove func(), err error) {
+	pid, running, err := readPidFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if running {
+		return nil, fmt.Errorf("daemon already running (pid %d)", pid)
+	}
+
+	pidFile, err := getPidFile()
+	if err != nil {
+		return nil, err
+	}
+
+	errWrite := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644)
+	if errWrite != nil {
+		return nil, fmt.Errorf("failed to write pid file: %v", errWrite)
+	}
+
+	remove = func() {
+		os.Remove(pidFile)
+	}
+
+	return remove, nil
+}
+
+func processAlive(pid int) bool {
+	// signal 0 only checks that the process exists
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func isDaemon(pid int) bool {
+	/*
+	* @brief tells whether pid is a running gator daemon, from its
+	* command line. Without /proc only the process existence is checked
+	*/
+	if _, errProc := os.Stat("/proc/self"); errProc != nil {
+		return processAlive(pid)
+	}
+
+	cmdline, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return false
+	}
+
+	args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	if filepath.Base(args[0]) != filepath.Base(os.Args[0]) {
+		return false
+	}
+
+	// 'gator [--output <format>] daemon ...'
+	args = args[1:]
+	if len(args) > 2 && args[0] == "--output" {
+		args = args[2:]
+	}
+
+	return len(args) > 0 && strings.EqualFold(args[0], "daemon")
+}
@@ -3,15 +3,19 @@ package commands
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"os/exec"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/niccolot/BlogAggregator/internal/auth"
 	"github.com/niccolot/BlogAggregator/internal/database"
+	"github.com/niccolot/BlogAggregator/internal/rss"
 	"github.com/niccolot/BlogAggregator/internal/state"
 )
 
@@ -110,16 +114,32 @@ func handlerGetUsers(s *state.State, cmd Command) error {
 		return fmt.Errorf("error while quering database: %v", errUsers)
 	}
 
+	if s.Output != OutputText {
+		records := make([]userRecord, 0, len(users))
+		for _, user := range(users) {
+			records = append(records, userRecord{
+				ID: user.ID,
+				CreatedAt: user.CreatedAt,
+				UpdatedAt: user.UpdatedAt,
+				Name: user.Name,
+				IsSuperuser: user.IsSuperuser.Bool,
+				IsCurrent: user.Name == s.Cfg.CurrentUserName,
+			})
+		}
+
+		return printRecords(s.Output, records)
+	}
+
 	for _, user := range users {
 		if user.Name == s.Cfg.CurrentUserName {
-			if user.Name == s.Cfg.SuperUserName {
+			if user.IsSuperuser.Bool {
 				fmt.Printf("* %s (current) (superuser)\n", user.Name)
 			} else {
 				fmt.Printf("* %s (current)\n", user.Name)
 			}
 			
 		} else {
-			if user.Name == s.Cfg.SuperUserName {
+			if user.IsSuperuser.Bool {
 				fmt.Printf("* %s (superuser)\n", user.Name)
 			} else {
 				fmt.Printf("* %s\n", user.Name)
@@ -153,32 +173,34 @@ func handlerAggregate(s *state.State, cmd Command, user *database.User) error {
 		log.Printf("Collecting feeds every %s...\n", timeBetweenReqs)
 	}
 	
-	// aggregation
-	ticker := time.NewTicker(timeBetweenReqs)
-	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	s.Aggregating = true
+	// stopagg cancels the aggregation, in-flight fetches included
+	go func() {
+		select {
+		case <-s.StopAggregation:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 
 	aggPars := &aggPars{
 		s: s,
 		timeBetweenReqs: timeBetweenReqs,
 		numFeeds: numFeeds,
 		logging: logging,
+		folderID: pars.folderID,
+		workers: pars.workers,
+		fetchTimeout: pars.fetchTimeout,
 	}
 
-	/*
-	every timeBetweenReqs a total of 'workers' goroutines
-	are spawned and a total of 'batchSize' feeds are fetched 
-	*/
-	for range ticker.C {
-		select {
-		case <-s.StopAggregation:
-			fmt.Println("Aggregation stopped")
-			return nil
-		default:
-			aggregate(aggPars)
-		}
-	} 
+	errAgg := runAggregation(ctx, aggPars)
+	if errAgg != nil {
+		return errAgg
+	}
+
+	fmt.Println("Aggregation stopped")
 
 	return nil
 }
@@ -243,14 +265,21 @@ func handlerReset(s *state.State, cmd Command, user *database.User) error {
 		return errSuper
 	}
 
-	errDeleteUsers := s.Db.ResetUsers(context.Background())
-	if errDeleteUsers != nil {
-		return fmt.Errorf("error while resetting users: %v", errDeleteUsers)
-	}
+	errReset := s.WithTx(context.Background(), func(q *database.Queries) error {
+		errDeleteFeeds := q.ResetFeeds(context.Background())
+		if errDeleteFeeds != nil {
+			return fmt.Errorf("error while resetting feeds: %v", errDeleteFeeds)
+		}
 
-	errDeleteFeeds := s.Db.ResetUsers(context.Background())
-	if errDeleteFeeds != nil {
-		return fmt.Errorf("error while resetting feeds: %v", errDeleteFeeds)
+		errDeleteUsers := q.ResetUsers(context.Background())
+		if errDeleteUsers != nil {
+			return fmt.Errorf("error while resetting users: %v", errDeleteUsers)
+		}
+
+		return nil
+	})
+	if errReset != nil {
+		return errReset
 	}
 
 	fmt.Println("database succesfully reset")
@@ -259,8 +288,37 @@ func handlerReset(s *state.State, cmd Command, user *database.User) error {
 }
 
 func handlerAddFeed(s *state.State, cmd Command, user *database.User) error {
-	if len(cmd.Args) != 2 {
-		return fmt.Errorf("usage: addfeed \"<feed name>\" \"<feed url>\"")
+	if len(cmd.Args) < 1 || len(cmd.Args) > 2 {
+		return fmt.Errorf("usage: addfeed [optional] \"<feed name>\" <feed url>")
+	}
+
+	var name string
+	pageURL := cmd.Args[0]
+	if len(cmd.Args) == 2 {
+		name = cmd.Args[0]
+		pageURL = cmd.Args[1]
+	}
+
+	// trial fetch, nothing is saved if the url does not lead to a valid feed
+	parsedFeed, feedURL, errDiscover := discoverFeed(pageURL)
+	if errDiscover != nil {
+		return errDiscover
+	}
+
+	fmt.Printf("Title: %s\n", parsedFeed.Title)
+	fmt.Printf("Format: %s\n", parsedFeed.Format)
+	fmt.Printf("Items: %d\n", len(parsedFeed.Entries))
+
+	if name == "" {
+		if parsedFeed.Title == "" {
+			return fmt.Errorf("the feed has no title, choose a name with: addfeed \"<feed name>\" <feed url>")
+		}
+
+		var errName error
+		name, errName = askWithDefault("Feed name", parsedFeed.Title)
+		if errName != nil {
+			return errName
+		}
 	}
 
 	currTime := time.Now()
@@ -270,16 +328,11 @@ func handlerAddFeed(s *state.State, cmd Command, user *database.User) error {
 		ID: newFeedID,
 		CreatedAt: currTime,
 		UpdatedAt: currTime,
-		Name: cmd.Args[0],
-		Url: cmd.Args[1],
+		Name: name,
+		Url: feedURL,
 		UserID: user.ID,
 	}
 
-	feed, errFeed := s.Db.CreateFeed(context.Background(), *feedPars)
-	if errFeed != nil {
-		return fmt.Errorf("error while creating feed in database: %v", errFeed)
-	}
-
 	feedFollowsPars := &database.CreateFeedFollowParams{
 		ID: uuid.New(),
 		CreatedAt: currTime,
@@ -288,11 +341,27 @@ func handlerAddFeed(s *state.State, cmd Command, user *database.User) error {
 		FeedID: newFeedID,
 	}
 
-	_, errFeedFollows := s.Db.CreateFeedFollow(context.Background(), *feedFollowsPars)
-	if errFeedFollows != nil {
-		return fmt.Errorf("error while updating following list: %v", errFeedFollows)
+	// feed and follow are saved together, a failed follow must not leave an orphan feed
+	var feed database.Feed
+	errTx := s.WithTx(context.Background(), func(q *database.Queries) error {
+		var errFeed error
+		feed, errFeed = q.CreateFeed(context.Background(), *feedPars)
+		if errFeed != nil {
+			return fmt.Errorf("error while creating feed in database: %v", errFeed)
+		}
+
+		_, errFeedFollows := q.CreateFeedFollow(context.Background(), *feedFollowsPars)
+		if errFeedFollows != nil {
+			return fmt.Errorf("error while updating following list: %v", errFeedFollows)
+		}
+
+		return nil
+	})
+	if errTx != nil {
+		return errTx
 	}
 
+	fmt.Println()
 	fmt.Printf("Feed name: %s\n", feed.Name)
 	fmt.Println(feed.ID)
 	fmt.Printf("Created at: %s\n", feed.CreatedAt)
@@ -312,11 +381,17 @@ func handlerFeeds(s *state.State, cmd Command) error {
 		return fmt.Errorf("error while retrieving feeds from database: %v", errFeeds)
 	}
 
+	var records []feedRecord
 	for _, feed := range(feeds) {
 		user, errUser := s.Db.GetuserFromID(context.Background(), feed.UserID)
 		if errUser != nil {
 			return fmt.Errorf("error while retrieving username from database; %v", errUser)
 		}
+
+		if s.Output != OutputText {
+			records = append(records, newFeedRecord(&feed, user.Name))
+			continue
+		}
 		
 		fmt.Println()
 		fmt.Printf("Feed name: %s\n", feed.Name)
@@ -326,6 +401,137 @@ func handlerFeeds(s *state.State, cmd Command) error {
 		fmt.Printf("URL: %s\n", feed.Url)
 		fmt.Printf("UserID: %s\n", feed.UserID)
 		fmt.Printf("Author name: %s\n", user.Name)
+		fmt.Printf("Fetch interval: %s\n", fetchInterval(&feed))
+		if feed.Disabled {
+			fmt.Printf("Status: disabled (last error: %s)\n", feed.LastError.String)
+		} else if feed.ConsecutiveFailures > 0 {
+			fmt.Printf("Status: %d consecutive failures, next try at %s\n", 
+				feed.ConsecutiveFailures, feed.NextFetchAt.Time)
+		} else if feed.NextFetchAt.Valid {
+			fmt.Printf("Next fetch: %s\n", feed.NextFetchAt.Time)
+		}
+	}
+
+
+	if s.Output != OutputText {
+		return printRecords(s.Output, records)
+	}
+	return nil
+}
+
+func handlerDisabledFeeds(s *state.State, cmd Command) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("usage: disabledfeeds")
+	}
+
+	feeds, errFeeds := s.Db.GetDisabledFeeds(context.Background())
+	if errFeeds != nil {
+		return fmt.Errorf("error while retrieving disabled feeds from database: %v", errFeeds)
+	}
+
+	if len(feeds) == 0 {
+		fmt.Println("no feed is currently disabled")
+		return nil
+	}
+
+	for _, feed := range(feeds) {
+		fmt.Println()
+		fmt.Printf("Feed name: %s\n", feed.Name)
+		fmt.Printf("URL: %s\n", feed.Url)
+		fmt.Printf("Consecutive failures: %d\n", feed.ConsecutiveFailures)
+		fmt.Printf("Last error: %s\n", feed.LastError.String)
+	}
+
+	return nil
+}
+
+func handlerEnableFeed(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: enablefeed <feed url> [or] enablefeed \"<feed name>\"")
+	}
+
+	// feeds are shared by all the users
+	errSuper := auth.CheckSuperUser(s, user)
+	if errSuper != nil {
+		return errSuper
+	}
+
+	pars := &database.EnableFeedParams{
+		Url: cmd.Args[0], // url or name
+		UpdatedAt: time.Now(),
+	}
+
+	enabled, errEnable := s.Db.EnableFeed(context.Background(), *pars)
+	if errEnable != nil {
+		return fmt.Errorf("error while enabling feed: %v", errEnable)
+	}
+
+	if enabled == 0 {
+		return fmt.Errorf("feed '%s' not found", cmd.Args[0])
+	}
+
+	fmt.Println("feed succesfully enabled")
+
+	return nil
+}
+
+func handlerSetInterval(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: setinterval <feed url> [or] setinterval \"<feed name>\" <duration>|default")
+	}
+
+	// feeds are shared by all the users
+	errSuper := auth.CheckSuperUser(s, user)
+	if errSuper != nil {
+		return errSuper
+	}
+
+	feed, err := s.Db.GetFeedFromURLOrName(context.Background(), cmd.Args[0])
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("feed '%s' not found", cmd.Args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to retrieve feed: %v", err)
+	}
+
+	pars := &database.SetFeedIntervalParams{
+		FetchIntervalSource: rss.IntervalDefault,
+		UpdatedAt: time.Now(),
+		ID: feed.ID,
+	}
+
+	// 'default' goes back to the hints of the feed
+	if cmd.Args[1] != "default" {
+		interval, errParse := time.ParseDuration(cmd.Args[1])
+		if errParse != nil {
+			return fmt.Errorf("invalid interval '%s': %v", cmd.Args[1], errParse)
+		}
+
+		if interval < time.Minute || interval > rss.MaxUserFetchInterval {
+			return fmt.Errorf("the interval must be between 1m and 1 year (%s)", rss.MaxUserFetchInterval)
+		}
+
+		pars.FetchInterval = sql.NullInt32{Int32: int32(interval.Seconds()), Valid: true}
+		pars.FetchIntervalSource = rss.IntervalUser
+	}
+
+	// due again one interval after the last fetch, never fetched feeds stay due.
+	// Failing feeds keep the next try of their backoff
+	feed.FetchInterval = pars.FetchInterval
+	feed.FetchIntervalSource = pars.FetchIntervalSource
+	if feed.LastFetchedAt.Valid {
+		pars.NextFetchAt = rss.NextFetchAt(&feed, feed.LastFetchedAt.Time)
+	}
+
+	err = s.Db.SetFeedInterval(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to set fetch interval: %v", err)
+	}
+
+	if pars.FetchIntervalSource == rss.IntervalDefault {
+		fmt.Printf("fetch interval of '%s' reset, the feed hints are used from the next fetch\n", feed.Name)
+	} else {
+		fmt.Printf("fetch interval of '%s' set to %s\n", feed.Name, fetchInterval(&feed))
 	}
 
 	return nil
@@ -361,26 +567,122 @@ func handlerFollow(s *state.State, cmd Command, user *database.User) error {
 	return nil
 }
 
+func handlerImport(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: import <file.opml>")
+	}
+
+	file, err := os.Open(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to open '%s': %v", cmd.Args[0], err)
+	}
+	defer file.Close()
+
+	subs, err := rss.ParseOPML(file)
+	if err != nil {
+		return err
+	}
+
+	if len(subs) == 0 {
+		return fmt.Errorf("no feeds found in '%s'", cmd.Args[0])
+	}
+
+	// every feed is imported on its own, a bad entry does not stop the others
+	var created, followed, failed int
+	for _, sub := range(subs) {
+		isNew, errImport := importSubscription(s, user, &sub)
+		if errImport != nil {
+			fmt.Printf("failed to import '%s': %v\n", sub.XMLURL, errImport)
+			failed++
+			continue
+		}
+
+		followed++
+		if isNew {
+			created++
+		}
+	}
+
+	fmt.Printf("%d feeds followed (%d new), %d failed\n", followed, created, failed)
+
+	return nil
+}
+
+func handlerExport(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: export <file.opml>")
+	}
+
+	following, err := s.Db.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error while retrieving followed feeds from database: %v", err)
+	}
+
+	subs := make([]rss.Subscription, 0, len(following))
+	for _, follow := range(following) {
+		subs = append(subs, rss.Subscription{
+			Title: follow.FeedName,
+			XMLURL: follow.FeedUrl,
+			HTMLURL: follow.FeedSiteUrl.String,
+			Folders: follow.Folders,
+		})
+	}
+
+	file, err := os.Create(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to create '%s': %v", cmd.Args[0], err)
+	}
+	defer file.Close()
+
+	err = rss.WriteOPML(file, fmt.Sprintf("%s subscriptions in Gator", user.Name), subs)
+	if err != nil {
+		return fmt.Errorf("failed to write '%s': %v", cmd.Args[0], err)
+	}
+
+	fmt.Printf("%d feeds exported to %s\n", len(subs), cmd.Args[0])
+
+	return nil
+}
+
 func handlerFollowing(s *state.State, cmd Command, user *database.User) error {
-	if len(cmd.Args) != 0 {
-		return fmt.Errorf("usage: following")
+	flags, positional, errFlags := parseFlags(cmd.Args, nil, []string{"folder"})
+	if errFlags != nil || len(positional) != 0 {
+		return fmt.Errorf("usage: following [optional] --folder <folder name>")
+	}
+
+	pars := &database.GetFollowedFeedsWithUnreadParams{
+		UserID: user.ID,
+	}
+
+	folderName, hasFolder := flags["folder"]
+	if hasFolder {
+		folderID, errFolder := getFolderID(s, user, folderName)
+		if errFolder != nil {
+			return errFolder
+		}
+		pars.FolderID = folderID
 	}
 	
-	following, errFollowing := s.Db.GetFeedFollowsForUser(context.Background(), user.ID)
+	following, errFollowing := s.Db.GetFollowedFeedsWithUnread(context.Background(), *pars)
 	if errFollowing != nil {
 		return fmt.Errorf("error while retrieving followed feeds from database: %v", errFollowing)
 	}
 
-	for _, feedFollow := range(following) {
-		feed, errFeed := s.Db.GetFeedFromID(context.Background(), feedFollow.FeedID)
-		if errFeed != nil {
-			fmt.Printf("error while retrieving followed feed details: %v", errFeed)
-			continue
+	if s.Output != OutputText {
+		records := make([]followedFeedRecord, 0, len(following))
+		for _, feed := range(following) {
+			records = append(records, followedFeedRecord(feed))
 		}
+
+		return printRecords(s.Output, records)
+	}
+
+	for _, feed := range(following) {
 		fmt.Println()
 		fmt.Println(feed.Name)
 		fmt.Println(feed.Url)
 		fmt.Println(feed.ID)
+		fmt.Printf("Unread posts: %d\n", feed.Unread)
 	}
 
 	return nil
@@ -407,9 +709,188 @@ func handlerUnfollow(s *state.State, cmd Command, user *database.User) error {
 	return nil
 }
 
+func handlerFolder(s *state.State, cmd Command, user *database.User) error {
+	usage := "usage: folder create|delete <folder> [or] folder rename <folder> <new name> [or] folder add|remove <folder> <feed url|name> [or] folder list"
+	if len(cmd.Args) < 1 {
+		return errors.New(usage)
+	}
+
+	action, args := cmd.Args[0], cmd.Args[1:]
+
+	var err error
+	switch {
+	case action == "create" && len(args) == 1:
+		err = folderCreate(s, user, args[0])
+	case action == "rename" && len(args) == 2:
+		err = folderRename(s, user, args[0], args[1])
+	case action == "delete" && len(args) == 1:
+		err = folderDelete(s, user, args[0])
+	case action == "add" && len(args) == 2:
+		err = folderAdd(s, user, args[0], args[1])
+	case action == "remove" && len(args) == 2:
+		err = folderRemove(s, user, args[0], args[1])
+	case action == "list" && len(args) == 0:
+		err = folderList(s, user)
+	default:
+		return errors.New(usage)
+	}
+
+	return err
+}
+
+func folderCreate(s *state.State, user *database.User, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("folder name cannot be empty")
+	}
+
+	pars := &database.CreateFolderParams{
+		ID: uuid.New(),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		UserID: user.ID,
+		Name: name,
+	}
+
+	_, err := s.Db.CreateFolder(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to create folder '%s': %v", name, err)
+	}
+
+	fmt.Printf("folder '%s' created\n", name)
+
+	return nil
+}
+
+func folderRename(s *state.State, user *database.User, name string, newName string) error {
+	if strings.TrimSpace(newName) == "" {
+		return fmt.Errorf("folder name cannot be empty")
+	}
+
+	pars := &database.RenameFolderParams{
+		NewName: newName,
+		UpdatedAt: time.Now(),
+		UserID: user.ID,
+		Name: name,
+	}
+
+	renamed, err := s.Db.RenameFolder(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to rename folder '%s': %v", name, err)
+	}
+
+	if renamed == 0 {
+		return fmt.Errorf("folder '%s' not found", name)
+	}
+
+	fmt.Printf("folder '%s' renamed to '%s'\n", name, newName)
+
+	return nil
+}
+
+func folderDelete(s *state.State, user *database.User, name string) error {
+	pars := &database.DeleteFolderParams{
+		UserID: user.ID,
+		Name: name,
+	}
+
+	// only the folder goes away, its feeds are still followed
+	deleted, err := s.Db.DeleteFolder(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to delete folder '%s': %v", name, err)
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("folder '%s' not found", name)
+	}
+
+	fmt.Printf("folder '%s' deleted\n", name)
+
+	return nil
+}
+
+func folderAdd(s *state.State, user *database.User, name string, feed string) error {
+	folderID, err := getFolderID(s, user, name)
+	if err != nil {
+		return err
+	}
+
+	follow, err := getFeedFollow(s, user, feed)
+	if err != nil {
+		return err
+	}
+
+	pars := &database.AddFeedToFolderParams{
+		FolderID: folderID.UUID,
+		FeedFollowID: follow.ID,
+	}
+
+	err = s.Db.AddFeedToFolder(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to add feed to folder '%s': %v", name, err)
+	}
+
+	fmt.Printf("feed '%s' added to folder '%s'\n", feed, name)
+
+	return nil
+}
+
+func folderRemove(s *state.State, user *database.User, name string, feed string) error {
+	folderID, err := getFolderID(s, user, name)
+	if err != nil {
+		return err
+	}
+
+	follow, err := getFeedFollow(s, user, feed)
+	if err != nil {
+		return err
+	}
+
+	pars := &database.RemoveFeedFromFolderParams{
+		FolderID: folderID.UUID,
+		FeedFollowID: follow.ID,
+	}
+
+	removed, err := s.Db.RemoveFeedFromFolder(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to remove feed from folder '%s': %v", name, err)
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("feed '%s' is not in folder '%s'", feed, name)
+	}
+
+	fmt.Printf("feed '%s' removed from folder '%s'\n", feed, name)
+
+	return nil
+}
+
+func folderList(s *state.State, user *database.User) error {
+	folders, err := s.Db.GetFoldersForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve folders: %v", err)
+	}
+
+	if len(folders) == 0 {
+		fmt.Println("no folders found")
+		return nil
+	}
+
+	for _, folder := range(folders) {
+		fmt.Println()
+		fmt.Println(folder.Name)
+		fmt.Printf("Feeds: %d\n", folder.Feeds)
+		fmt.Printf("Unread posts: %d\n", folder.Unread)
+	}
+
+	return nil
+}
+
 func handlerBrowse(s *state.State, cmd Command, user *database.User) error {
-	if len(cmd.Args) > 1 {
-		return fmt.Errorf("usage: browse [optional] <limit>")
+	flags, positional, errFlags := parseFlags(cmd.Args,
+		[]string{"unread", "all"},
+		[]string{"feed", "folder", "since", "until", "offset", "page", "order", "search"})
+	if errFlags != nil || len(positional) > 1 {
+		return fmt.Errorf("usage: browse [optional] --unread|--all --feed <name|url> --folder <folder name> --since <date|duration> --until <date|duration> --offset <n>|--page <n> --order published|fetched --search <keyword> <limit>")
 	}
 
 	following, errFollowing := s.Db.GetFeedFollowsForUser(context.Background(), user.ID)
@@ -421,11 +902,9 @@ func handlerBrowse(s *state.State, cmd Command, user *database.User) error {
 		return fmt.Errorf("no feed is being currently followed")
 	}
 
-	var limitStr string;
-	if len(cmd.Args) == 0 {
-		limitStr = "2" // 2 posts as default limit
-	} else {
-		limitStr = cmd.Args[0]
+	limitStr := "2" // 2 posts as default limit
+	if len(positional) == 1 {
+		limitStr = positional[0]
 	}
 
 	limit, errConv := strconv.ParseInt(limitStr, 10, 32)
@@ -435,18 +914,123 @@ func handlerBrowse(s *state.State, cmd Command, user *database.User) error {
 
 	getPostsPars := &database.GetPostsForUserParams{
 		UserID: user.ID,
+		UnreadOnly: flags["unread"] == "true" && flags["all"] != "true",
+		OrderBy: "fetched",
 		Limit: int32(limit), // ParseInt is bugged and always returns int64 regardless of the choice
 	}
 
+	if feed, ok := flags["feed"]; ok {
+		getPostsPars.Feed = sql.NullString{String: feed, Valid: true}
+	}
+
+	if folderName, ok := flags["folder"]; ok {
+		folderID, errFolder := getFolderID(s, user, folderName)
+		if errFolder != nil {
+			return errFolder
+		}
+		getPostsPars.FolderID = folderID
+	}
+
+	if keyword, ok := flags["search"]; ok {
+		getPostsPars.Keyword = sql.NullString{String: keyword, Valid: true}
+	}
+
+	if order, ok := flags["order"]; ok {
+		if order != "published" && order != "fetched" {
+			return fmt.Errorf("invalid order '%s', use 'published' or 'fetched'", order)
+		}
+		getPostsPars.OrderBy = order
+	}
+
+	for _, name := range([]string{"since", "until"}) {
+		value, ok := flags[name]
+		if !ok {
+			continue
+		}
+
+		t, errTime := parseTimeArg(value)
+		if errTime != nil {
+			return errTime
+		}
+
+		if name == "since" {
+			getPostsPars.Since = sql.NullTime{Time: t, Valid: true}
+		} else {
+			getPostsPars.Until = sql.NullTime{Time: t, Valid: true}
+		}
+	}
+
+	_, hasOffset := flags["offset"]
+	_, hasPage := flags["page"]
+	if hasOffset && hasPage {
+		return fmt.Errorf("use either --offset or --page, not both")
+	}
+
+	if hasOffset {
+		offset, errOffset := strconv.ParseInt(flags["offset"], 10, 32)
+		if errOffset != nil || offset < 0 {
+			return fmt.Errorf("invalid offset '%s'", flags["offset"])
+		}
+		getPostsPars.Offset = int32(offset)
+	}
+
+	if hasPage {
+		page, errPage := strconv.ParseInt(flags["page"], 10, 32)
+		if errPage != nil || page < 1 {
+			return fmt.Errorf("invalid page '%s'", flags["page"])
+		}
+		getPostsPars.Offset = int32(page - 1) * int32(limit)
+	}
+
 	posts, errPosts := s.Db.GetPostsForUser(context.Background(), *getPostsPars)
 	if errPosts != nil {
 		return fmt.Errorf("failed to get posts from database: %v", errPosts)
 	}
 
+	if s.Output != OutputText {
+		records := make([]postRecord, 0, len(posts))
+		for _, post := range(posts) {
+			records = append(records, postRecord{
+				ID: post.ID,
+				Title: post.Title.String,
+				Url: post.Url,
+				PublishedAt: nullTime(post.PublishedAt),
+				Description: post.Description.String,
+				FeedName: post.FeedName,
+				IsRead: post.IsRead,
+			})
+		}
+
+		errPrint := printRecords(s.Output, records)
+		if errPrint != nil {
+			return errPrint
+		}
+	}
+
+	if len(posts) == 0 && s.Output == OutputText {
+		fmt.Println("no posts found")
+		return nil
+	}
+
+	readAt := time.Now()
 	for _, post := range(posts) {
+		// shown posts are marked as read whatever the format
+		errRead := markRead(s, user, post.ID, readAt)
+		if errRead != nil {
+			return errRead
+		}
+
+		if s.Output != OutputText {
+			continue
+		}
+
 		fmt.Println()
 		fmt.Println("Feed: ", post.FeedName)
-		fmt.Println(post.Title.String)
+		if post.IsRead {
+			fmt.Println(post.Title.String)
+		} else {
+			fmt.Println(post.Title.String, "(new)")
+		}
 		fmt.Println("Published at: ", post.PublishedAt.Time)
 		fmt.Println("Link: ", post.Url)
 	}
@@ -454,7 +1038,7 @@ func handlerBrowse(s *state.State, cmd Command, user *database.User) error {
 	return nil
 }
 
-func handlerOpen(s *state.State, cmd Command) error {
+func handlerOpen(s *state.State, cmd Command, user *database.User) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: open <post url> [or] <post name>")
 	}
@@ -469,11 +1053,150 @@ func handlerOpen(s *state.State, cmd Command) error {
 		return fmt.Errorf("error opening url: %v", errOpen)
 	}
 
+	errRead := markRead(s, user, post.ID, time.Now())
+	if errRead != nil {
+		return errRead
+	}
+
 	fmt.Println("opening post in default browser...")
 
 	return nil
 }
 
+func handlerMarkRead(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: markread <feed url> [or] markread \"<feed name>\" [or] markread all")
+	}
+
+	var marked int64
+	var errMark error
+	if cmd.Args[0] == "all" {
+		pars := &database.MarkAllPostsReadParams{
+			UserID: user.ID,
+			ReadAt: time.Now(),
+		}
+		marked, errMark = s.Db.MarkAllPostsRead(context.Background(), *pars)
+	} else {
+		// a typo would otherwise just mark 0 posts
+		followPars := &database.GetFeedFollowForFeedParams{
+			UserID: user.ID,
+			Feed: cmd.Args[0],
+		}
+		_, errFollow := s.Db.GetFeedFollowForFeed(context.Background(), *followPars)
+		if errors.Is(errFollow, sql.ErrNoRows) {
+			return fmt.Errorf("feed '%s' not found or not followed", cmd.Args[0])
+		}
+		if errFollow != nil {
+			return fmt.Errorf("failed to retrieve followed feed: %v", errFollow)
+		}
+
+		pars := &database.MarkFeedPostsReadParams{
+			UserID: user.ID,
+			ReadAt: time.Now(),
+			Feed: cmd.Args[0], // url or name
+		}
+		marked, errMark = s.Db.MarkFeedPostsRead(context.Background(), *pars)
+	}
+
+	if errMark != nil {
+		return fmt.Errorf("failed to mark posts as read: %v", errMark)
+	}
+
+	fmt.Printf("%d posts marked as read", marked)
+
+	return nil
+}
+
+func handlerMarkUnread(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: markunread <post url> [or] markunread \"<post title>\"")
+	}
+
+	post, err := s.Db.GetPost(context.Background(), cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to find post: %v", err)
+	}
+
+	pars := &database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	}
+
+	unmarked, err := s.Db.MarkPostUnread(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to mark post as unread: %v", err)
+	}
+
+	if unmarked == 0 {
+		fmt.Println("post already unread")
+		return nil
+	}
+
+	fmt.Println("post marked as unread")
+
+	return nil
+}
+
+func handlerSearch(s *state.State, cmd Command, user *database.User) error {
+	flags, positional, errFlags := parseFlags(cmd.Args,
+		[]string{"following", "bookmarks"},
+		[]string{"limit"})
+	if errFlags != nil || len(positional) != 1 {
+		return fmt.Errorf("usage: search \"<query>\" [optional] --following|--bookmarks --limit <n>")
+	}
+
+	scope := "all"
+	if flags["following"] == "true" {
+		scope = "following"
+	}
+	if flags["bookmarks"] == "true" {
+		if scope != "all" {
+			return fmt.Errorf("use either --following or --bookmarks, not both")
+		}
+		scope = "bookmarks"
+	}
+
+	limit := int64(10)
+	if limitStr, ok := flags["limit"]; ok {
+		var errConv error
+		limit, errConv = strconv.ParseInt(limitStr, 10, 32)
+		if errConv != nil {
+			return fmt.Errorf("failed to parse limit value: %v", errConv)
+		}
+	}
+
+	pars := &database.SearchPostsParams{
+		Query: positional[0],
+		Scope: scope,
+		UserID: user.ID,
+		Limit: int32(limit),
+	}
+
+	results, err := s.Db.SearchPosts(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to search posts: %v", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("no posts found")
+		return nil
+	}
+
+	// ts_headline marks the matches with << >>, shown in bold
+	highlighter := strings.NewReplacer("<<", "\u001b[1m", ">>", "\u001b[0m")
+
+	for _, result := range(results) {
+		fmt.Println()
+		fmt.Println("Feed: ", result.FeedName)
+		fmt.Println(result.Title.String)
+		fmt.Println("Published at: ", result.PublishedAt.Time)
+		fmt.Println("Link: ", result.Url)
+		fmt.Println(highlighter.Replace(result.Snippet))
+	}
+
+	return nil
+}
+
 func handlerChangeSuperUser(s *state.State, cmd Command, user *database.User) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: changesuper <new superuser>")
@@ -489,9 +1212,22 @@ func handlerChangeSuperUser(s *state.State, cmd Command, user *database.User) er
 		return fmt.Errorf("error while looking for selected user: %v", err)
 	}
 
-	err = s.Db.UpdateToSuper(context.Background(), newSuper.ID)
+	// demoting first, only one superuser is allowed at a time
+	err = s.WithTx(context.Background(), func(q *database.Queries) error {
+		errDemote := q.DemoteSuperUsers(context.Background(), newSuper.ID)
+		if errDemote != nil {
+			return fmt.Errorf("failed to remove superuser privileges from '%s': %v", user.Name, errDemote)
+		}
+
+		errPromote := q.UpdateToSuper(context.Background(), newSuper.ID)
+		if errPromote != nil {
+			return fmt.Errorf("failed to set user '%s' as superuser: %v", newSuper.Name, errPromote)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to set user '%s' as superuser: %v", newSuper.Name, err)
+		return err
 	}
 
 	user.IsSuperuser = sql.NullBool{Valid: true, Bool: false}
@@ -499,6 +1235,8 @@ func handlerChangeSuperUser(s *state.State, cmd Command, user *database.User) er
 	s.Cfg.SuperUserID = newSuper.ID
 	s.Cfg.SuperUserName = newSuper.Name
 
+	fmt.Printf("user '%s' is now the superuser", newSuper.Name)
+
 	return nil
 }
 
@@ -507,7 +1245,7 @@ func handlerChangePassword(s *state.State, cmd Command, user *database.User) err
 		return fmt.Errorf("usage: changepassword [superuser only] <account name>")
 	}
 
-	if s.Cfg.SuperUserID != user.ID {
+	if !user.IsSuperuser.Bool {
 		err := auth.ChangePassword(user, s)
 		if err != nil {
 			fmt.Println(
@@ -573,26 +1311,243 @@ func handlerBookmark(s *state.State, cmd Command, user *database.User) error {
 	return nil
 }
 
+func handlerBookmarks(s *state.State, cmd Command, user *database.User) error {
+	flags, positional, errFlags := parseFlags(cmd.Args, nil, []string{"tag"})
+	if errFlags != nil || len(positional) != 0 {
+		return fmt.Errorf("usage: bookmarks [optional] --tag <tag>")
+	}
+
+	pars := &database.GetBookmarkedPostsForUserParams{
+		UserID: user.ID,
+	}
+
+	tag, hasTag := flags["tag"]
+	if hasTag {
+		pars.Tag = sql.NullString{String: normalizeTag(tag), Valid: true}
+	}
+
+	bookmarks, err := s.Db.GetBookmarkedPostsForUser(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to get bookmarks from database: %v", err)
+	}
+
+	if s.Output != OutputText {
+		records := make([]bookmarkRecord, 0, len(bookmarks))
+		for _, bookmark := range(bookmarks) {
+			records = append(records, bookmarkRecord{
+				ID: bookmark.ID,
+				CreatedAt: bookmark.CreatedAt,
+				Title: bookmark.Title.String,
+				Url: bookmark.Url,
+				PublishedAt: nullTime(bookmark.PublishedAt),
+				FeedName: bookmark.FeedName,
+				Tags: bookmark.Tags,
+				Note: bookmark.Note.String,
+			})
+		}
+
+		return printRecords(s.Output, records)
+	}
+
+	if len(bookmarks) == 0 {
+		fmt.Println("no bookmarks found")
+		return nil
+	}
+
+	for _, bookmark := range(bookmarks) {
+		fmt.Println()
+		fmt.Println("Feed: ", bookmark.FeedName)
+		fmt.Println(bookmark.Title.String)
+		fmt.Println("Published at: ", bookmark.PublishedAt.Time)
+		fmt.Println("Link: ", bookmark.Url)
+		if len(bookmark.Tags) > 0 {
+			fmt.Println("Tags: ", strings.Join(bookmark.Tags, ", "))
+		}
+		if bookmark.Note.Valid {
+			fmt.Println("Note: ", bookmark.Note.String)
+		}
+	}
+
+	return nil
+}
+
+func handlerUnbookmark(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: unbookmark <post title> [or] <post url>")
+	}
+
+	post, err := s.Db.GetPost(context.Background(), cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("failed to retrieve post '%s': %v", cmd.Args[0], err)
+	}
+
+	pars := &database.DeleteBookmarkParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	}
+
+	// tags are deleted along with the bookmark
+	deleted, err := s.Db.DeleteBookmark(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to remove bookmark: %v", err)
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("post '%s' is not bookmarked", cmd.Args[0])
+	}
+
+	fmt.Println("bookmark removed")
+
+	return nil
+}
+
+func handlerTag(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) < 2 {
+		return fmt.Errorf("usage: tag <post title> [or] <post url> <tag> [optional] <more tags>")
+	}
+
+	bookmark, err := getBookmark(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range(cmd.Args[1:]) {
+		if normalizeTag(tag) == "" {
+			return fmt.Errorf("tags cannot be empty")
+		}
+
+		pars := &database.AddBookmarkTagParams{
+			BookmarkID: bookmark.ID,
+			Tag: normalizeTag(tag),
+		}
+
+		errTag := s.Db.AddBookmarkTag(context.Background(), *pars)
+		if errTag != nil {
+			return fmt.Errorf("failed to add tag '%s': %v", tag, errTag)
+		}
+	}
+
+	fmt.Println("bookmark tagged")
+
+	return nil
+}
+
+func handlerUntag(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: untag <post title> [or] <post url> <tag>")
+	}
+
+	bookmark, err := getBookmark(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	pars := &database.RemoveBookmarkTagParams{
+		BookmarkID: bookmark.ID,
+		Tag: normalizeTag(cmd.Args[1]),
+	}
+
+	removed, err := s.Db.RemoveBookmarkTag(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to remove tag: %v", err)
+	}
+
+	if removed == 0 {
+		return fmt.Errorf("bookmark has no tag '%s'", cmd.Args[1])
+	}
+
+	fmt.Println("tag removed")
+
+	return nil
+}
+
+func handlerNote(s *state.State, cmd Command, user *database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: note <post title> [or] <post url> \"<note>\"")
+	}
+
+	bookmark, err := getBookmark(s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+
+	// an empty note clears the previous one
+	note := strings.TrimSpace(cmd.Args[1])
+	pars := &database.SetBookmarkNoteParams{
+		ID: bookmark.ID,
+		Note: sql.NullString{String: note, Valid: note != ""},
+	}
+
+	err = s.Db.SetBookmarkNote(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to save note: %v", err)
+	}
+
+	if note == "" {
+		fmt.Println("note removed")
+	} else {
+		fmt.Println("note saved")
+	}
+
+	return nil
+}
+
+func handlerOutput(s *state.State, cmd Command) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("usage: output [optional] %s", strings.Join(outputFormats, "|"))
+	}
+
+	if len(cmd.Args) == 0 {
+		fmt.Printf("output format: %s\n", s.Output)
+		return nil
+	}
+
+	if !IsOutputFormat(cmd.Args[0]) {
+		return fmt.Errorf("unknown output format '%s', use one of %s", cmd.Args[0], strings.Join(outputFormats, ", "))
+	}
+
+	// for the rest of the session, the default is in the config file
+	s.Output = cmd.Args[0]
+
+	return nil
+}
+
 func handlerHelp(s *state.State, cmd Command) error {
 	usages := map[string]string{
 		"login": "usage: login <username> - Logs in a user with the specified username.",
 		"register": "usage: register <username> - Registers a new user with the specified username.",
 		"users": "usage: users - Displays the list of registered users.",
-		"aggregate": "usage: aggregate <time between reqs> [optional] -log- Starts aggregating feeds and optionally logs the aggreagtion in a file",
+		"aggregate": "usage: aggregate <time between reqs> [optional] -log --folder <folder name> --workers <n> --timeout <duration> - Starts aggregating feeds, optionally only the ones in a folder, and optionally logs the aggreagtion in a file",
 		"stopagg": "usage: stopagg - Stops the ongoing feed aggregation.",
+		"daemon": "usage: daemon <time between reqs> [optional] -log --workers <n> --timeout <duration> [or] daemon status|stop - Aggregates every followed feed in the foreground until SIGINT/SIGTERM, or checks/stops the running daemon.",
 		"resetusers": "usage: resetusers - Deletes all users from the system.",
 		"resetfeeds": "usage: resetfeeds - Deletes all feeds from the system.",
 		"reset": "usage: reset - Resets the entire database.",
-		"addfeed": "usage: addfeed \"<feed name>\" \"<feed url>\" - Adds a new feed with the given name and URL.",
+		"addfeed": "usage: addfeed [optional] \"<feed name>\" <feed url> - Checks and adds a new feed, by default named after its title.",
 		"feeds": "usage: feeds - Lists all available feeds.",
+		"disabledfeeds": "usage: disabledfeeds - Lists the feeds disabled after too many failed fetches.",
+		"enablefeed": "usage: enablefeed <feed url> [or] enablefeed \"<feed name>\" - Re-enables a disabled feed (superuser only).",
+		"setinterval": "usage: setinterval <feed url> [or] setinterval \"<feed name>\" <duration>|default - Sets how often a feed is fetched, default uses the interval declared by the feed (superuser only).",
 		"follow": "usage: follow <feed url> - Follows a feed using its URL.",
-		"following": "usage: following - Shows the feeds the user is following.",
+		"import": "usage: import <file.opml> - Adds and follows the feeds of an OPML file, its folders become the feed folders.",
+		"export": "usage: export <file.opml> - Writes the followed feeds to an OPML file.",
+		"following": "usage: following [optional] --folder <folder name> - Shows the feeds the user is following, or the ones in a folder, and their unread posts.",
+		"folder": "usage: folder create|delete <folder> [or] folder rename <folder> <new name> [or] folder add|remove <folder> <feed url|name> [or] folder list - Organizes the followed feeds in folders.",
 		"unfollow": "usage: unfollow <feed url> [or] unfollow \"<feed name>\" - Unfollows a feed by URL or name.",
-		"browse": "usage: browse [optional] <limit> - Browses recent posts from followed feeds.",
+		"browse": "usage: browse [optional] --unread|--all --feed <name|url> --folder <folder name> --since <date|duration> --until <date|duration> --offset <n>|--page <n> --order published|fetched --search <keyword> <limit> - Browses posts from followed feeds, marking them as read.",
+		"markread": "usage: markread <feed url> [or] markread \"<feed name>\" [or] markread all - Marks all the posts of a feed, or of every followed feed, as read.",
+		"markunread": "usage: markunread <post url> [or] markunread \"<post title>\" - Marks a post as unread.",
+		"search": "usage: search \"<query>\" [optional] --following|--bookmarks --limit <n> - Full-text search over the stored posts, supports \"phrases\", or and -excluded words.",
 		"open": "usage: open <post url> [or] <post name> - Opens a post in the default web browser.",
 		"changesuper": "usage: changesuper <new superuser> - Changes the superuser to a new specified user.",
 		"changepassword": "usage: changepassword [superuser only] <account name> - Changes the password of an account.",
+		"output": "usage: output [optional] text|json|csv|tsv - Shows or sets the output format of feeds, following, users, browse and bookmarks, a single command accepts --output <format> too.",
 		"bookmark": "usage: bookmark <post title> [or] <post url> - Bookmarks a post by title or URL.",
+		"bookmarks": "usage: bookmarks [optional] --tag <tag> - Lists the bookmarked posts, optionally only the ones with a tag.",
+		"unbookmark": "usage: unbookmark <post title> [or] <post url> - Removes a bookmark.",
+		"tag": "usage: tag <post title> [or] <post url> <tag> [optional] <more tags> - Tags a bookmarked post.",
+		"untag": "usage: untag <post title> [or] <post url> <tag> - Removes a tag from a bookmarked post.",
+		"note": "usage: note <post title> [or] <post url> \"<note>\" - Attaches a personal note to a bookmarked post, an empty note removes it.",
 	}
 
 	fmt.Println("Available commands:")
@@ -0,0 +1,218 @@
+package commands
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/niccolot/BlogAggregator/internal/database"
+)
+
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+	OutputCSV  = "csv"
+	OutputTSV  = "tsv"
+)
+
+var outputFormats = []string{OutputText, OutputJSON, OutputCSV, OutputTSV}
+
+/*
+records printed by the listing commands, field names mirror
+the database structs so that they are stable for scripts
+*/
+
+type feedRecord struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	UserID              uuid.UUID
+	UserName            string
+	LastFetchedAt       *time.Time
+	LastError           string
+	ConsecutiveFailures int32
+	NextFetchAt         *time.Time
+	Disabled            bool
+	FetchInterval       *int32 // seconds
+	FetchIntervalSource string
+}
+
+type followedFeedRecord struct {
+	ID     uuid.UUID
+	Name   string
+	Url    string
+	Unread int64
+}
+
+type userRecord struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string
+	IsSuperuser bool
+	IsCurrent   bool
+}
+
+type postRecord struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt *time.Time
+	Description string
+	FeedName    string
+	IsRead      bool
+}
+
+type bookmarkRecord struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	Title       string
+	Url         string
+	PublishedAt *time.Time
+	FeedName    string
+	Tags        []string
+	Note        string
+}
+
+func IsOutputFormat(format string) bool {
+	return slices.Contains(outputFormats, format)
+}
+
+func extractOutputFlag(args []string) (format string, rest []string, err error) {
+	/*
+	* @brief takes the global '--output <format>' option out of the
+	* arguments of a command
+	*
+	* @return format, rest (string, []string): the format, empty if not
+	* given, and the remaining arguments
+	*/
+	for i := 0; i < len(args); i++ {
+		if args[i] != "--output" {
+			rest = append(rest, args[i])
+			continue
+		}
+
+		if i+1 >= len(args) || !IsOutputFormat(args[i+1]) {
+			return "", nil, fmt.Errorf("usage: --output %s", strings.Join(outputFormats, "|"))
+		}
+		format = args[i+1]
+		i++
+	}
+
+	return format, rest, nil
+}
+
+func printRecords[T any](format string, records []T) error {
+	/*
+	* @brief prints the records as a json array or as csv/tsv
+	* with a header row, not used for the text output
+	*/
+	if format == OutputJSON {
+		if records == nil {
+			records = []T{}
+		}
+
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(records)
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	if format == OutputTSV {
+		writer.Comma = '\t'
+	}
+
+	recordType := reflect.TypeFor[T]()
+	header := make([]string, recordType.NumField())
+	for i := range(header) {
+		header[i] = recordType.Field(i).Name
+	}
+
+	errWrite := writer.Write(header)
+	if errWrite != nil {
+		return errWrite
+	}
+
+	for _, record := range(records) {
+		value := reflect.ValueOf(record)
+		row := make([]string, value.NumField())
+		for i := range(row) {
+			row[i] = formatField(value.Field(i).Interface())
+		}
+
+		errWrite = writer.Write(row)
+		if errWrite != nil {
+			return errWrite
+		}
+	}
+
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func formatField(field any) string {
+	switch v := field.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case *time.Time:
+		if v == nil {
+			return ""
+		}
+		return v.Format(time.RFC3339)
+	case *int32:
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprint(*v)
+	case []string:
+		return strings.Join(v, ";")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+func nullInt32(n sql.NullInt32) *int32 {
+	if !n.Valid {
+		return nil
+	}
+
+	return &n.Int32
+}
+
+func newFeedRecord(feed *database.Feed, userName string) feedRecord {
+	return feedRecord{
+		ID: feed.ID,
+		CreatedAt: feed.CreatedAt,
+		UpdatedAt: feed.UpdatedAt,
+		Name: feed.Name,
+		Url: feed.Url,
+		UserID: feed.UserID,
+		UserName: userName,
+		LastFetchedAt: nullTime(feed.LastFetchedAt),
+		LastError: feed.LastError.String,
+		ConsecutiveFailures: feed.ConsecutiveFailures,
+		NextFetchAt: nullTime(feed.NextFetchAt),
+		Disabled: feed.Disabled,
+		FetchInterval: nullInt32(feed.FetchInterval),
+		FetchIntervalSource: feed.FetchIntervalSource,
+	}
+}
@@ -1,9 +1,10 @@
 package commands
 
 import (
-	"sync"
+	"context"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/niccolot/BlogAggregator/internal/database"
 	"github.com/niccolot/BlogAggregator/internal/state"
 )
@@ -12,19 +13,30 @@ type aggInitPars struct {
 	numFollowing int
 	timeBetweenReqs time.Duration
 	logging bool
+	folderID uuid.NullUUID // only the feeds in this folder, if valid
+	workers int
+	fetchTimeout time.Duration
 }
 
 type aggPars struct {
 	s *state.State
 	timeBetweenReqs time.Duration
-	numFeeds int
+	numFeeds int // feeds fetched per cycle at most
 	logging bool
+	folderID uuid.NullUUID
+	workers int
+	fetchTimeout time.Duration
 }
 
 type workerPars struct {
 	s *state.State
-	wg *sync.WaitGroup
-	feedQueue *[]database.Feed
-	timeBetweenReqs time.Duration
-	logging bool
+	ctx context.Context // cancelled when the aggregation stops
+	fetchTimeout time.Duration
+}
+
+type fetchResult struct {
+	workerID int
+	feed database.Feed
+	err error
+	elapsed time.Duration
 }
\ No newline at end of file
@@ -2,13 +2,20 @@ package commands
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/niccolot/BlogAggregator/internal/auth"
 	"github.com/niccolot/BlogAggregator/internal/database"
+	"github.com/niccolot/BlogAggregator/internal/rss"
 	"github.com/niccolot/BlogAggregator/internal/state"
 )
 
@@ -56,6 +63,178 @@ func ParseInput(text string) (command string, args []string) {
 	return strings.ToLower(command), args
 }
 
+func markRead(s *state.State, user *database.User, postID uuid.UUID, readAt time.Time) error {
+	pars := &database.MarkPostReadParams{
+		PostID: postID,
+		UserID: user.ID,
+		ReadAt: readAt,
+	}
+
+	err := s.Db.MarkPostRead(context.Background(), *pars)
+	if err != nil {
+		return fmt.Errorf("failed to mark post as read: %v", err)
+	}
+
+	return nil
+}
+
+func getBookmark(s *state.State, user *database.User, postArg string) (*database.UserPost, error) {
+	/*
+	* @brief finds the user's bookmark of a post given by title or url
+	*/
+	post, err := s.Db.GetPost(context.Background(), postArg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve post '%s': %v", postArg, err)
+	}
+
+	pars := &database.GetBookmarkParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	}
+
+	bookmark, err := s.Db.GetBookmark(context.Background(), *pars)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("post '%s' is not bookmarked", postArg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bookmark: %v", err)
+	}
+
+	return &bookmark, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func importSubscription(s *state.State, user *database.User, sub *rss.Subscription) (bool, error) {
+	/*
+	* @brief creates the feed if it is missing, follows it and puts it in
+	* its folders, all in one transaction. Returns whether the feed is new
+	*/
+	ctx := context.Background()
+	currTime := time.Now()
+	var created bool
+
+	errTx := s.WithTx(ctx, func(q *database.Queries) error {
+		feed, errFeed := q.GetFeedFromURL(ctx, sub.XMLURL)
+		if errors.Is(errFeed, sql.ErrNoRows) {
+			name, errName := uniqueFeedName(ctx, q, sub.Title, sub.XMLURL)
+			if errName != nil {
+				return errName
+			}
+
+			feedPars := &database.CreateFeedParams{
+				ID: uuid.New(),
+				CreatedAt: currTime,
+				UpdatedAt: currTime,
+				Name: name,
+				Url: sub.XMLURL,
+				UserID: user.ID,
+			}
+
+			feed, errFeed = q.CreateFeed(ctx, *feedPars)
+			if errFeed != nil {
+				return fmt.Errorf("error while creating feed in database: %v", errFeed)
+			}
+
+			if sub.HTMLURL != "" {
+				siteURLPars := &database.UpdateFeedSiteURLParams{
+					ID: feed.ID,
+					SiteUrl: sql.NullString{String: sub.HTMLURL, Valid: true},
+				}
+
+				errSite := q.UpdateFeedSiteURL(ctx, *siteURLPars)
+				if errSite != nil {
+					return errSite
+				}
+			}
+
+			created = true
+		} else if errFeed != nil {
+			return fmt.Errorf("error while retrieving feed from database: %v", errFeed)
+		}
+
+		followPars := &database.GetFeedFollowParams{
+			UserID: user.ID,
+			FeedID: feed.ID,
+		}
+
+		follow, errFollow := q.GetFeedFollow(ctx, *followPars)
+		if errors.Is(errFollow, sql.ErrNoRows) {
+			follow = database.FeedFollow{ID: uuid.New()}
+			feedFollowPars := &database.CreateFeedFollowParams{
+				ID: follow.ID,
+				CreatedAt: currTime,
+				UpdatedAt: currTime,
+				UserID: user.ID,
+				FeedID: feed.ID,
+			}
+
+			_, errFollow = q.CreateFeedFollow(ctx, *feedFollowPars)
+			if errFollow != nil {
+				return fmt.Errorf("error while following feed: %v", errFollow)
+			}
+		} else if errFollow != nil {
+			return fmt.Errorf("error while retrieving followed feed: %v", errFollow)
+		}
+
+		for _, name := range(sub.Folders) {
+			folderPars := &database.UpsertFolderParams{
+				ID: uuid.New(),
+				CreatedAt: currTime,
+				UpdatedAt: currTime,
+				UserID: user.ID,
+				Name: name,
+			}
+
+			folder, errFolder := q.UpsertFolder(ctx, *folderPars)
+			if errFolder != nil {
+				return fmt.Errorf("error while creating folder '%s': %v", name, errFolder)
+			}
+
+			addPars := &database.AddFeedToFolderParams{
+				FolderID: folder.ID,
+				FeedFollowID: follow.ID,
+			}
+
+			errAdd := q.AddFeedToFolder(ctx, *addPars)
+			if errAdd != nil {
+				return fmt.Errorf("error while adding feed to folder '%s': %v", name, errAdd)
+			}
+		}
+
+		return nil
+	})
+
+	return created, errTx
+}
+
+func uniqueFeedName(ctx context.Context, q *database.Queries, title string, feedURL string) (string, error) {
+	/*
+	* @brief feed names are unique, an already taken title
+	* gets a numeric suffix, e.g. 'Blog (2)'
+	*/
+	base := strings.TrimSpace(title)
+	if base == "" {
+		base = feedURL
+	}
+
+	name := base
+	for i := 2; ; i++ {
+		taken, err := q.FeedNameExists(ctx, name)
+		if err != nil {
+			return "", err
+		}
+
+		if !taken {
+			return name, nil
+		}
+
+		name = fmt.Sprintf("%s (%d)", base, i)
+	}
+}
+
 func setLogger(filename string) (*os.File, error) {
 	logFile, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
 	if err != nil {
@@ -82,30 +261,52 @@ func setLogger(filename string) (*os.File, error) {
 }
 
 func parseAggregationInputs(s *state.State, cmd *Command, user *database.User) (pars aggInitPars, err error) {
-	if len(cmd.Args) < 1 {
-		return aggInitPars{}, fmt.Errorf("usage: aggregate <time between requests> [optional] -log")
+	usage := "usage: aggregate <time between requests> [optional] -log --folder <folder name> --workers <n> --timeout <duration>"
+	flags, positional, errFlags := parseFlags(cmd.Args, nil, []string{"folder", "workers", "timeout"})
+	if errFlags != nil || len(positional) < 1 || len(positional) > 2 {
+		return aggInitPars{}, errors.New(usage)
 	}
 
 	var log bool
-	if len(cmd.Args) == 2 {
-		if cmd.Args[1] != "-log" {
-			return aggInitPars{}, fmt.Errorf("usage: aggregate <time between requests> [optional] -log")
+	if len(positional) == 2 {
+		if positional[1] != "-log" {
+			return aggInitPars{}, errors.New(usage)
 		} else {
 			log = true
 		}
 	}
 
+	workers, fetchTimeout, errPool := parsePoolFlags(s, flags)
+	if errPool != nil {
+		return aggInitPars{}, errPool
+	}
+
+	var folderID uuid.NullUUID
+	folderName, hasFolder := flags["folder"]
+	if hasFolder {
+		folderID, err = getFolderID(s, user, folderName)
+		if err != nil {
+			return aggInitPars{}, err
+		}
+	}
+
 	following, errFollowing := s.Db.GetFeedFollowsForUser(context.Background(), user.ID)
 	if errFollowing != nil {
 		return aggInitPars{}, fmt.Errorf("error while retrieving followed feeds from database: %v", errFollowing)
 	}
 
-	numFollowing := len(following)
+	var numFollowing int
+	for _, follow := range(following) {
+		if !hasFolder || slices.Contains(follow.Folders, folderName) {
+			numFollowing++
+		}
+	}
+
 	if numFollowing == 0 {
 		return aggInitPars{}, fmt.Errorf("no feed is being currently followed")
 	}
 
-	timeBetweenReqs, errParse := time.ParseDuration(cmd.Args[0])
+	timeBetweenReqs, errParse := time.ParseDuration(positional[0])
 	if errParse != nil {
 		return aggInitPars{}, fmt.Errorf("error while parsing fetching frequency: %v", errParse)
 	}
@@ -119,8 +320,254 @@ func parseAggregationInputs(s *state.State, cmd *Command, user *database.User) (
 		numFollowing: numFollowing,
 		timeBetweenReqs: timeBetweenReqs,
 		logging: log,
+		folderID: folderID,
+		workers: workers,
+		fetchTimeout: fetchTimeout,
 	}
 
 
 	return pars, nil
-}
\ No newline at end of file
+}
+
+func parsePoolFlags(s *state.State, flags map[string]string) (workers int, fetchTimeout time.Duration, err error) {
+	/*
+	* @brief worker count and per-fetch timeout of the aggregation, from
+	* the '--workers' and '--timeout' flags, the config file or the defaults
+	*/
+	workers = defaultWorkers
+	fetchTimeout = defaultFetchTimeout
+
+	if s.Cfg != nil && s.Cfg.Workers > 0 {
+		workers = s.Cfg.Workers
+	}
+
+	if s.Cfg != nil && s.Cfg.FetchTimeout != "" {
+		fetchTimeout, err = time.ParseDuration(s.Cfg.FetchTimeout)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid fetch_timeout '%s' in config: %v", s.Cfg.FetchTimeout, err)
+		}
+	}
+
+	if value, ok := flags["workers"]; ok {
+		workers, err = strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return 0, 0, fmt.Errorf("invalid number of workers '%s'", value)
+		}
+	}
+
+	if value, ok := flags["timeout"]; ok {
+		fetchTimeout, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid timeout '%s': %v", value, err)
+		}
+	}
+
+	if fetchTimeout <= 0 {
+		return 0, 0, fmt.Errorf("the fetch timeout must be positive")
+	}
+
+	// checked once here rather than failing every fetch
+	_, _, err = rss.FetchIntervalBounds(s.Cfg)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return workers, fetchTimeout, nil
+}
+
+func fetchInterval(feed *database.Feed) string {
+	if !feed.FetchInterval.Valid {
+		return fmt.Sprintf("every aggregation cycle (%s)", feed.FetchIntervalSource)
+	}
+
+	interval := time.Duration(feed.FetchInterval.Int32) * time.Second
+
+	return fmt.Sprintf("%s (%s)", interval, feed.FetchIntervalSource)
+}
+
+func getFeedFollow(s *state.State, user *database.User, feed string) (*database.FeedFollow, error) {
+	pars := &database.GetFeedFollowForFeedParams{
+		UserID: user.ID,
+		Feed: feed,
+	}
+
+	follow, err := s.Db.GetFeedFollowForFeed(context.Background(), *pars)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("feed '%s' is not followed", feed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve followed feed: %v", err)
+	}
+
+	return &follow, nil
+}
+
+func getFolderID(s *state.State, user *database.User, name string) (uuid.NullUUID, error) {
+	pars := &database.GetFolderParams{
+		UserID: user.ID,
+		Name: name,
+	}
+
+	folder, err := s.Db.GetFolder(context.Background(), *pars)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.NullUUID{}, fmt.Errorf("folder '%s' not found", name)
+	}
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("failed to retrieve folder '%s': %v", name, err)
+	}
+
+	return uuid.NullUUID{UUID: folder.ID, Valid: true}, nil
+}
+
+func askChoice(prompt string, numChoices int) (int, error) {
+	/*
+	* @brief asks the user to pick one of numChoices options,
+	* listed starting from 1
+	*
+	* @return choice (int): the 0-based index of the selected option
+	*/
+	fmt.Printf("%s [1-%d]: ", prompt, numChoices)
+
+	line, err := auth.ReadLine()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read choice: %v", err)
+	}
+
+	choice, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || choice < 1 || choice > numChoices {
+		return 0, fmt.Errorf("invalid choice '%s'", strings.TrimSpace(line))
+	}
+
+	return choice - 1, nil
+}
+
+func discoverFeed(pageURL string) (*rss.Feed, string, error) {
+	/*
+	* @brief resolves the url given to addfeed to an actual feed url,
+	* looking for the feeds advertised by the page if it is a web page,
+	* and fetches it to make sure it is a valid feed
+	*
+	* @return feed, feedURL (*rss.Feed, string): the parsed feed and its url
+	*/
+	ctxDiscover, cancelDiscover := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelDiscover()
+
+	candidates, err := rss.Discover(ctxDiscover, pageURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to find a feed at '%s': %v", pageURL, err)
+	}
+
+	candidate := candidates[0]
+	if len(candidates) == 1 {
+		if candidate.URL != pageURL {
+			fmt.Printf("Found feed '%s' at %s\n", candidate.Title, candidate.URL)
+		}
+	} else {
+		fmt.Println("Multiple feeds found:")
+		for i, c := range(candidates) {
+			fmt.Printf("%d) %s (%s) %s\n", i+1, c.Title, c.Type, c.URL)
+		}
+
+		choice, err := askChoice("Select the feed to add", len(candidates))
+		if err != nil {
+			return nil, "", err
+		}
+
+		candidate = candidates[choice]
+	}
+
+	// feeds only linked by a page have not been fetched yet
+	// a timeout of its own, the user may take a while to choose
+	feed := candidate.Feed
+	if feed == nil {
+		ctxPreview, cancelPreview := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancelPreview()
+
+		feed, err = rss.Preview(ctxPreview, candidate.URL)
+		if err != nil {
+			return nil, "", fmt.Errorf("'%s' is not a valid feed: %v", candidate.URL, err)
+		}
+	}
+
+	return feed, candidate.URL, nil
+}
+
+func askWithDefault(prompt string, defaultValue string) (string, error) {
+	/*
+	* @brief asks the user for a value, an empty answer selects defaultValue
+	*/
+	fmt.Printf("%s [%s]: ", prompt, defaultValue)
+
+	line, err := auth.ReadLine()
+	if err != nil {
+		return "", fmt.Errorf("failed to read answer: %v", err)
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return defaultValue, nil
+	}
+
+	return answer, nil
+}
+
+func parseFlags(args []string, boolFlags []string, valueFlags []string) (flags map[string]string, positional []string, err error) {
+	/*
+	* @brief splits the arguments in '--flag', '--flag <value>' and positional ones
+	*
+	* @param boolFlags ([]string): flags without a value, set to "true" if present
+	* @param valueFlags ([]string): flags followed by a value
+	*
+	* @return flags, positional (map[string]string, []string): the flags found
+	* (without the leading '--') and the remaining arguments
+	*/
+	flags = make(map[string]string)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg, "--")
+		switch {
+		case slices.Contains(boolFlags, name):
+			flags[name] = "true"
+		case slices.Contains(valueFlags, name):
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("missing value for flag '%s'", arg)
+			}
+			flags[name] = args[i+1]
+			i++
+		default:
+			return nil, nil, fmt.Errorf("unknown flag '%s'", arg)
+		}
+	}
+
+	return flags, positional, nil
+}
+
+func parseTimeArg(value string) (time.Time, error) {
+	/*
+	* @brief parses a point in time given either as a date (2006-01-02,
+	* RFC3339) or as a duration ago (90m, 24h, 7d)
+	*/
+	if days, found := strings.CutSuffix(value, "d"); found {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+
+	for _, layout := range([]string{time.DateOnly, time.DateTime, time.RFC3339}) {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time '%s', use a date (2006-01-02) or a duration (24h, 7d)", value)
+}
@@ -11,6 +11,15 @@ type Config struct {
 	SuperUserName string `json:"superuser_name"`
 	SuperUserID uuid.UUID `json:"superuser_id"`
 	CmdHistory  []string `json:"cmd_history"`
+	MaxFeedSize int64 `json:"max_feed_size"` // bytes, 0 uses the default
+	Output string `json:"output"` // default output format, text if empty
+	Workers int `json:"workers"` // concurrent fetches during aggregation, 0 uses the default
+	FetchTimeout string `json:"fetch_timeout"` // e.g. "30s", empty uses the default
+	MinFetchInterval string `json:"min_fetch_interval"` // bounds of the automatic feed intervals,
+	MaxFetchInterval string `json:"max_fetch_interval"` // e.g. "15m" and "12h", empty uses the defaults
+	HostConcurrency int `json:"host_concurrency"` // concurrent fetches from the same host, 0 uses the default
+	HostSpacing string `json:"host_spacing"` // min time between fetches from the same host, e.g. "2s"
+	MaxRequestsPerSecond float64 `json:"max_requests_per_second"` // all hosts together, 0 uses the default
 }
 
 func Read() *Config {
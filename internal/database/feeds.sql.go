@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createFeed = `-- name: CreateFeed :one
@@ -23,7 +24,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, next_fetch_at, disabled, redirect_url, redirect_count, site_url, fetch_interval, fetch_interval_source, skip_hours, skip_days
 `
 
 type CreateFeedParams struct {
@@ -53,12 +54,122 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.SiteUrl,
+		&i.FetchInterval,
+		&i.FetchIntervalSource,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :execrows
+UPDATE feeds
+SET disabled = FALSE,
+	last_error = NULL,
+	consecutive_failures = 0,
+	next_fetch_at = NULL,
+	updated_at = $2
+WHERE url = $1 OR name = $1
+`
+
+type EnableFeedParams struct {
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) EnableFeed(ctx context.Context, arg EnableFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, enableFeed, arg.Url, arg.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const feedNameExists = `-- name: FeedNameExists :one
+SELECT EXISTS (
+    SELECT 1 FROM feeds
+    WHERE name = $1
+)
+`
+
+func (q *Queries) FeedNameExists(ctx context.Context, name string) (bool, error) {
+	row := q.db.QueryRowContext(ctx, feedNameExists, name)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const getDisabledFeeds = `-- name: GetDisabledFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, next_fetch_at, disabled, redirect_url, redirect_count, site_url, fetch_interval, fetch_interval_source, skip_hours, skip_days FROM feeds
+WHERE disabled = TRUE
+ORDER BY name
+`
+
+func (q *Queries) GetDisabledFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getDisabledFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.SiteUrl,
+			&i.FetchInterval,
+			&i.FetchIntervalSource,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getFeedFromID = `-- name: GetFeedFromID :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, next_fetch_at, disabled, redirect_url, redirect_count, site_url, fetch_interval, fetch_interval_source, skip_hours, skip_days FROM feeds
 WHERE id = $1
 `
 
@@ -73,12 +184,25 @@ func (q *Queries) GetFeedFromID(ctx context.Context, id uuid.UUID) (Feed, error)
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.SiteUrl,
+		&i.FetchInterval,
+		&i.FetchIntervalSource,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
 	)
 	return i, err
 }
 
 const getFeedFromURL = `-- name: GetFeedFromURL :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds 
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, next_fetch_at, disabled, redirect_url, redirect_count, site_url, fetch_interval, fetch_interval_source, skip_hours, skip_days FROM feeds 
 WHERE url = $1
 `
 
@@ -93,12 +217,60 @@ func (q *Queries) GetFeedFromURL(ctx context.Context, url string) (Feed, error)
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.SiteUrl,
+		&i.FetchInterval,
+		&i.FetchIntervalSource,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+	)
+	return i, err
+}
+
+const getFeedFromURLOrName = `-- name: GetFeedFromURLOrName :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, next_fetch_at, disabled, redirect_url, redirect_count, site_url, fetch_interval, fetch_interval_source, skip_hours, skip_days FROM feeds
+WHERE url = $1 OR name = $1
+ORDER BY url = $1 DESC
+LIMIT 1
+`
+
+func (q *Queries) GetFeedFromURLOrName(ctx context.Context, feed string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFromURLOrName, feed)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.LastError,
+		&i.ConsecutiveFailures,
+		&i.NextFetchAt,
+		&i.Disabled,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.SiteUrl,
+		&i.FetchInterval,
+		&i.FetchIntervalSource,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
 	)
 	return i, err
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, last_error, consecutive_failures, next_fetch_at, disabled, redirect_url, redirect_count, site_url, fetch_interval, fetch_interval_source, skip_hours, skip_days FROM feeds
 `
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
@@ -118,6 +290,19 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.SiteUrl,
+			&i.FetchInterval,
+			&i.FetchIntervalSource,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
 		); err != nil {
 			return nil, err
 		}
@@ -133,14 +318,28 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getNextFeedsToFetch = `-- name: GetNextFeedsToFetch :many
-SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at
-FROM feeds INNER JOIN feed_follows ON feeds.id = feed_follows.feed_id
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.etag, feeds.last_modified, feeds.last_error, feeds.consecutive_failures, feeds.next_fetch_at, feeds.disabled, feeds.redirect_url, feeds.redirect_count, feeds.site_url, feeds.fetch_interval, feeds.fetch_interval_source, feeds.skip_hours, feeds.skip_days
+FROM feeds
+WHERE feeds.disabled = FALSE
+AND (feeds.next_fetch_at IS NULL OR feeds.next_fetch_at <= $1)
+AND EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND ($2::uuid IS NULL OR feed_follows.id IN (
+        SELECT feed_follow_id FROM folder_feeds WHERE folder_id = $2))
+)
 ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT $1
+LIMIT $3
 `
 
-func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, limit)
+type GetNextFeedsToFetchParams struct {
+	NextFetchAt sql.NullTime
+	FolderID    uuid.NullUUID
+	Limit       int32
+}
+
+func (q *Queries) GetNextFeedsToFetch(ctx context.Context, arg GetNextFeedsToFetchParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getNextFeedsToFetch, arg.NextFetchAt, arg.FolderID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -156,6 +355,19 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.LastError,
+			&i.ConsecutiveFailures,
+			&i.NextFetchAt,
+			&i.Disabled,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.SiteUrl,
+			&i.FetchInterval,
+			&i.FetchIntervalSource,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
 		); err != nil {
 			return nil, err
 		}
@@ -170,20 +382,71 @@ func (q *Queries) GetNextFeedsToFetch(ctx context.Context, limit int32) ([]Feed,
 	return items, nil
 }
 
+const markFeedFailed = `-- name: MarkFeedFailed :exec
+UPDATE feeds
+SET last_error = $2,
+	consecutive_failures = $3,
+	next_fetch_at = $4,
+	disabled = $5,
+	updated_at = $6
+WHERE id = $1
+`
+
+type MarkFeedFailedParams struct {
+	ID                  uuid.UUID
+	LastError           sql.NullString
+	ConsecutiveFailures int32
+	NextFetchAt         sql.NullTime
+	Disabled            bool
+	UpdatedAt           time.Time
+}
+
+func (q *Queries) MarkFeedFailed(ctx context.Context, arg MarkFeedFailedParams) error {
+	_, err := q.db.ExecContext(ctx, markFeedFailed,
+		arg.ID,
+		arg.LastError,
+		arg.ConsecutiveFailures,
+		arg.NextFetchAt,
+		arg.Disabled,
+		arg.UpdatedAt,
+	)
+	return err
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $2, 
-	updated_at = $2
+	updated_at = $2,
+	last_error = NULL,
+	consecutive_failures = 0,
+	next_fetch_at = $3
 WHERE id = $1
 `
 
 type MarkFeedFetchedParams struct {
 	ID            uuid.UUID
 	LastFetchedAt sql.NullTime
+	NextFetchAt   sql.NullTime
 }
 
 func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.LastFetchedAt)
+	_, err := q.db.ExecContext(ctx, markFeedFetched, arg.ID, arg.LastFetchedAt, arg.NextFetchAt)
+	return err
+}
+
+const postponeFeed = `-- name: PostponeFeed :exec
+UPDATE feeds
+SET next_fetch_at = $2
+WHERE id = $1
+`
+
+type PostponeFeedParams struct {
+	ID          uuid.UUID
+	NextFetchAt sql.NullTime
+}
+
+func (q *Queries) PostponeFeed(ctx context.Context, arg PostponeFeedParams) error {
+	_, err := q.db.ExecContext(ctx, postponeFeed, arg.ID, arg.NextFetchAt)
 	return err
 }
 
@@ -195,3 +458,133 @@ func (q *Queries) ResetFeeds(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, resetFeeds)
 	return err
 }
+
+const setFeedInterval = `-- name: SetFeedInterval :exec
+UPDATE feeds
+SET fetch_interval = $1,
+	fetch_interval_source = $2,
+	next_fetch_at = CASE WHEN consecutive_failures = 0 THEN $3 ELSE next_fetch_at END,
+	etag = CASE WHEN $2 = 'user' THEN etag ELSE NULL END,
+	last_modified = CASE WHEN $2 = 'user' THEN last_modified ELSE NULL END,
+	updated_at = $4
+WHERE id = $5
+`
+
+type SetFeedIntervalParams struct {
+	FetchInterval       sql.NullInt32
+	FetchIntervalSource string
+	NextFetchAt         sql.NullTime
+	UpdatedAt           time.Time
+	ID                  uuid.UUID
+}
+
+func (q *Queries) SetFeedInterval(ctx context.Context, arg SetFeedIntervalParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedInterval,
+		arg.FetchInterval,
+		arg.FetchIntervalSource,
+		arg.NextFetchAt,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	return err
+}
+
+const setFeedRedirect = `-- name: SetFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = $2,
+	redirect_count = $3
+WHERE id = $1
+`
+
+type SetFeedRedirectParams struct {
+	ID            uuid.UUID
+	RedirectUrl   sql.NullString
+	RedirectCount int32
+}
+
+func (q *Queries) SetFeedRedirect(ctx context.Context, arg SetFeedRedirectParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRedirect, arg.ID, arg.RedirectUrl, arg.RedirectCount)
+	return err
+}
+
+const updateFeedSchedule = `-- name: UpdateFeedSchedule :exec
+UPDATE feeds
+SET fetch_interval = $2,
+	fetch_interval_source = $3,
+	skip_hours = $4,
+	skip_days = $5
+WHERE id = $1
+`
+
+type UpdateFeedScheduleParams struct {
+	ID                  uuid.UUID
+	FetchInterval       sql.NullInt32
+	FetchIntervalSource string
+	SkipHours           []int32
+	SkipDays            []int32
+}
+
+func (q *Queries) UpdateFeedSchedule(ctx context.Context, arg UpdateFeedScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedSchedule,
+		arg.ID,
+		arg.FetchInterval,
+		arg.FetchIntervalSource,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+	)
+	return err
+}
+
+const updateFeedSiteURL = `-- name: UpdateFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2
+WHERE id = $1
+`
+
+type UpdateFeedSiteURLParams struct {
+	ID      uuid.UUID
+	SiteUrl sql.NullString
+}
+
+func (q *Queries) UpdateFeedSiteURL(ctx context.Context, arg UpdateFeedSiteURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedSiteURL, arg.ID, arg.SiteUrl)
+	return err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET url = $2,
+	updated_at = $3,
+	redirect_url = NULL,
+	redirect_count = 0
+WHERE id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID        uuid.UUID
+	Url       string
+	UpdatedAt time.Time
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url, arg.UpdatedAt)
+	return err
+}
+
+const updateFeedValidators = `-- name: UpdateFeedValidators :exec
+UPDATE feeds
+SET etag = $2,
+	last_modified = $3
+WHERE id = $1
+`
+
+type UpdateFeedValidatorsParams struct {
+	ID           uuid.UUID
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedValidators(ctx context.Context, arg UpdateFeedValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedValidators, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
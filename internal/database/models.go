@@ -11,14 +11,32 @@ import (
 	"github.com/google/uuid"
 )
 
+type BookmarkTag struct {
+	BookmarkID uuid.UUID
+	Tag        string
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	UserID              uuid.UUID
+	LastFetchedAt       sql.NullTime
+	Etag                sql.NullString
+	LastModified        sql.NullString
+	LastError           sql.NullString
+	ConsecutiveFailures int32
+	NextFetchAt         sql.NullTime
+	Disabled            bool
+	RedirectUrl         sql.NullString
+	RedirectCount       int32
+	SiteUrl             sql.NullString
+	FetchInterval       sql.NullInt32
+	FetchIntervalSource string
+	SkipHours           []int32
+	SkipDays            []int32
 }
 
 type FeedFollow struct {
@@ -29,15 +47,37 @@ type FeedFollow struct {
 	FeedID    uuid.UUID
 }
 
+type Folder struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
+type FolderFeed struct {
+	FolderID     uuid.UUID
+	FeedFollowID uuid.UUID
+}
+
 type Post struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       sql.NullString
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        sql.NullString
+	Url          string
+	Description  sql.NullString
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	Guid         string
+	Content      sql.NullString
+	SearchVector interface{}
+}
+
+type PostRead struct {
+	PostID uuid.UUID
+	UserID uuid.UUID
+	ReadAt time.Time
 }
 
 type User struct {
@@ -54,4 +94,5 @@ type UserPost struct {
 	CreatedAt time.Time
 	UserID    uuid.UUID
 	PostID    uuid.UUID
+	Note      sql.NullString
 }
@@ -13,66 +13,69 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (
-    id, 
-    created_at,
-    updated_at,
-    title,
-    url,
-    description,
-    published_at,
-    feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
-) RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const adoptLegacyPostGuid = `-- name: AdoptLegacyPostGuid :exec
+UPDATE posts
+SET guid = $1
+WHERE feed_id = $2
+AND url = $3
+AND guid = url
+AND NOT EXISTS (
+    SELECT 1
+    FROM posts p
+    WHERE p.feed_id = $2 AND p.guid = $1
+)
 `
 
-type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       sql.NullString
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+type AdoptLegacyPostGuidParams struct {
+	Guid   string
+	FeedID uuid.UUID
+	Url    string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
-	)
-	var i Post
+func (q *Queries) AdoptLegacyPostGuid(ctx context.Context, arg AdoptLegacyPostGuidParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPostGuid, arg.Guid, arg.FeedID, arg.Url)
+	return err
+}
+
+const getFeedPublishGaps = `-- name: GetFeedPublishGaps :one
+SELECT
+    COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY gap), 0)::float8 AS median_gap,
+    COUNT(*) AS gaps
+FROM (
+    SELECT EXTRACT(EPOCH FROM published_at - LAG(published_at) OVER (ORDER BY published_at)) AS gap
+    FROM (
+        SELECT published_at
+        FROM posts
+        WHERE feed_id = $1 AND published_at IS NOT NULL
+        ORDER BY published_at DESC
+        LIMIT $2::int
+    ) recent
+) gaps
+WHERE gap > 0
+`
+
+type GetFeedPublishGapsParams struct {
+	FeedID   uuid.UUID
+	MaxPosts int32
+}
+
+type GetFeedPublishGapsRow struct {
+	MedianGap float64
+	Gaps      int64
+}
+
+func (q *Queries) GetFeedPublishGaps(ctx context.Context, arg GetFeedPublishGapsParams) (GetFeedPublishGapsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPublishGaps, arg.FeedID, arg.MaxPosts)
+	var i GetFeedPublishGapsRow
 	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
+		&i.MedianGap,
+		&i.Gaps,
 	)
 	return i, err
 }
 
 const getPost = `-- name: GetPost :one
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content, search_vector FROM posts
 WHERE url = $1 OR title = $1
 `
 
@@ -88,12 +91,15 @@ func (q *Queries) GetPost(ctx context.Context, url string) (Post, error) {
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.Content,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
 const getPostFromTitle = `-- name: GetPostFromTitle :one
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content, search_vector FROM posts
 WHERE title = $1
 `
 
@@ -109,12 +115,15 @@ func (q *Queries) GetPostFromTitle(ctx context.Context, title sql.NullString) (P
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.Content,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
 const getPostFromUrl = `-- name: GetPostFromUrl :one
-SELECT id, created_at, updated_at, title, url, description, published_at, feed_id FROM posts
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content, search_vector FROM posts
 WHERE url = $1
 `
 
@@ -130,6 +139,9 @@ func (q *Queries) GetPostFromUrl(ctx context.Context, url string) (Post, error)
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.Content,
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -137,38 +149,85 @@ func (q *Queries) GetPostFromUrl(ctx context.Context, url string) (Post, error)
 const getPostsForUser = `-- name: GetPostsForUser :many
 WITH users_posts AS (
     SELECT feed_follows.feed_id,
-            feeds.name 
+            feeds.name,
+            feeds.url
     FROM feed_follows 
     INNER JOIN feeds ON feed_follows.feed_id = feeds.id 
     WHERE feed_follows.user_id = $1
+    AND ($2::uuid IS NULL OR feed_follows.id IN (
+        SELECT feed_follow_id FROM folder_feeds WHERE folder_id = $2))
 )
 SELECT 
+    posts.id,
     posts.title,
     posts.url,
     posts.published_at,
     posts.description,
-    users_posts.name AS feed_name
+    users_posts.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::bool AS is_read
 FROM posts
 INNER JOIN users_posts ON users_posts.feed_id = posts.feed_id
-ORDER BY COALESCE(posts.created_at, posts.updated_at) DESC
-LIMIT $2
+LEFT JOIN post_reads ON post_reads.post_id = posts.id
+    AND post_reads.user_id = $1
+WHERE (NOT $3::bool OR post_reads.post_id IS NULL)
+AND ($4::text IS NULL
+    OR users_posts.name = $4
+    OR users_posts.url = $4)
+AND ($5::text IS NULL
+    OR posts.title ILIKE '%' || $5 || '%'
+    OR posts.description ILIKE '%' || $5 || '%')
+AND ($6::timestamp IS NULL
+    OR CASE WHEN $7::text = 'published'
+        THEN COALESCE(posts.published_at, posts.created_at)
+        ELSE posts.created_at END >= $6)
+AND ($8::timestamp IS NULL
+    OR CASE WHEN $7::text = 'published'
+        THEN COALESCE(posts.published_at, posts.created_at)
+        ELSE posts.created_at END < $8)
+ORDER BY CASE WHEN $7::text = 'published'
+    THEN COALESCE(posts.published_at, posts.created_at)
+    ELSE posts.created_at END DESC,
+    posts.id
+LIMIT $9
+OFFSET $10
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	UserID     uuid.UUID
+	FolderID   uuid.NullUUID
+	UnreadOnly bool
+	Feed       sql.NullString
+	Keyword    sql.NullString
+	Since      sql.NullTime
+	OrderBy    string
+	Until      sql.NullTime
+	Limit      int32
+	Offset     int32
 }
 
 type GetPostsForUserRow struct {
+	ID          uuid.UUID
 	Title       sql.NullString
 	Url         string
 	PublishedAt sql.NullTime
 	Description sql.NullString
 	FeedName    string
+	IsRead      bool
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.FolderID,
+		arg.UnreadOnly,
+		arg.Feed,
+		arg.Keyword,
+		arg.Since,
+		arg.OrderBy,
+		arg.Until,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -177,11 +236,114 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	for rows.Next() {
 		var i GetPostsForUserRow
 		if err := rows.Scan(
+			&i.ID,
 			&i.Title,
 			&i.Url,
 			&i.PublishedAt,
 			&i.Description,
 			&i.FeedName,
+			&i.IsRead,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = $1
+WHERE feed_id = $2
+AND guid NOT IN (
+    SELECT guid
+    FROM posts
+    WHERE feed_id = $1
+)
+`
+
+type MovePostsParams struct {
+	ToFeedID   uuid.UUID
+	FromFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.ToFeedID, arg.FromFeedID)
+	return err
+}
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        COALESCE(posts.title, '') || ' ' || COALESCE(posts.description, '') || ' ' || COALESCE(posts.content, ''),
+        query,
+        'StartSel=<<, StopSel=>>, MaxWords=25, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+CROSS JOIN websearch_to_tsquery('english', $1) AS query
+WHERE posts.search_vector @@ query
+AND ($2::text = 'all'
+    OR ($2 = 'following' AND posts.feed_id IN (
+        SELECT feed_id FROM feed_follows WHERE user_id = $3))
+    OR ($2 = 'bookmarks' AND posts.id IN (
+        SELECT post_id FROM user_posts WHERE user_id = $3)))
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT $4
+`
+
+type SearchPostsParams struct {
+	Query  string
+	Scope  string
+	UserID uuid.UUID
+	Limit  int32
+}
+
+type SearchPostsRow struct {
+	ID          uuid.UUID
+	Title       sql.NullString
+	Url         string
+	PublishedAt sql.NullTime
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPosts,
+		arg.Query,
+		arg.Scope,
+		arg.UserID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
 		); err != nil {
 			return nil, err
 		}
@@ -211,3 +373,69 @@ func (q *Queries) UpdatePost(ctx context.Context, arg UpdatePostParams) error {
 	_, err := q.db.ExecContext(ctx, updatePost, arg.ID, arg.UpdatedAt)
 	return err
 }
+
+const upsertPost = `-- name: UpsertPost :exec
+INSERT INTO posts (
+    id,
+    created_at,
+    updated_at,
+    title,
+    url,
+    description,
+    published_at,
+    feed_id,
+    guid,
+    content)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    published_at = COALESCE(EXCLUDED.published_at, posts.published_at),
+    updated_at = EXCLUDED.updated_at
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+OR posts.url IS DISTINCT FROM EXCLUDED.url
+OR posts.description IS DISTINCT FROM EXCLUDED.description
+OR posts.content IS DISTINCT FROM EXCLUDED.content
+`
+
+type UpsertPostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Guid        string
+	Content     sql.NullString
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) error {
+	_, err := q.db.ExecContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.Content,
+	)
+	return err
+}
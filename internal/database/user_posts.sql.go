@@ -7,11 +7,32 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const addBookmarkTag = `-- name: AddBookmarkTag :exec
+INSERT INTO bookmark_tags (bookmark_id, tag)
+VALUES (
+    $1,
+    $2
+)
+ON CONFLICT (bookmark_id, tag) DO NOTHING
+`
+
+type AddBookmarkTagParams struct {
+	BookmarkID uuid.UUID
+	Tag        string
+}
+
+func (q *Queries) AddBookmarkTag(ctx context.Context, arg AddBookmarkTagParams) error {
+	_, err := q.db.ExecContext(ctx, addBookmarkTag, arg.BookmarkID, arg.Tag)
+	return err
+}
+
 const bookmarkPost = `-- name: BookmarkPost :one
 INSERT INTO user_posts (id, created_at, user_id, post_id)
 VALUES (
@@ -20,7 +41,7 @@ VALUES (
     $3,
     $4
 )
-RETURNING id, created_at, user_id, post_id
+RETURNING id, created_at, user_id, post_id, note
 `
 
 type BookmarkPostParams struct {
@@ -43,29 +64,110 @@ func (q *Queries) BookmarkPost(ctx context.Context, arg BookmarkPostParams) (Use
 		&i.CreatedAt,
 		&i.UserID,
 		&i.PostID,
+		&i.Note,
+	)
+	return i, err
+}
+
+const deleteBookmark = `-- name: DeleteBookmark :execrows
+DELETE FROM user_posts
+WHERE user_id = $1 AND post_id = $2
+`
+
+type DeleteBookmarkParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) DeleteBookmark(ctx context.Context, arg DeleteBookmarkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBookmark, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getBookmark = `-- name: GetBookmark :one
+SELECT id, created_at, user_id, post_id, note FROM user_posts
+WHERE user_id = $1 AND post_id = $2
+`
+
+type GetBookmarkParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) GetBookmark(ctx context.Context, arg GetBookmarkParams) (UserPost, error) {
+	row := q.db.QueryRowContext(ctx, getBookmark, arg.UserID, arg.PostID)
+	var i UserPost
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.PostID,
+		&i.Note,
 	)
 	return i, err
 }
 
 const getBookmarkedPostsForUser = `-- name: GetBookmarkedPostsForUser :many
-SELECT id, created_at, user_id, post_id FROM user_posts
-WHERE user_id = $1
+SELECT
+    user_posts.id,
+    user_posts.created_at,
+    user_posts.note,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    COALESCE(
+        array_agg(bookmark_tags.tag ORDER BY bookmark_tags.tag) FILTER (WHERE bookmark_tags.tag IS NOT NULL),
+        '{}'
+    )::text[] AS tags
+FROM user_posts
+INNER JOIN posts ON posts.id = user_posts.post_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN bookmark_tags ON bookmark_tags.bookmark_id = user_posts.id
+WHERE user_posts.user_id = $1
+AND ($2::text IS NULL OR user_posts.id IN (
+    SELECT bookmark_id FROM bookmark_tags WHERE tag = $2))
+GROUP BY user_posts.id, posts.id, feeds.name
+ORDER BY user_posts.created_at DESC
 `
 
-func (q *Queries) GetBookmarkedPostsForUser(ctx context.Context, userID uuid.UUID) ([]UserPost, error) {
-	rows, err := q.db.QueryContext(ctx, getBookmarkedPostsForUser, userID)
+type GetBookmarkedPostsForUserParams struct {
+	UserID uuid.UUID
+	Tag    sql.NullString
+}
+
+type GetBookmarkedPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	Note        sql.NullString
+	Title       sql.NullString
+	Url         string
+	PublishedAt sql.NullTime
+	FeedName    string
+	Tags        []string
+}
+
+func (q *Queries) GetBookmarkedPostsForUser(ctx context.Context, arg GetBookmarkedPostsForUserParams) ([]GetBookmarkedPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBookmarkedPostsForUser, arg.UserID, arg.Tag)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []UserPost
+	var items []GetBookmarkedPostsForUserRow
 	for rows.Next() {
-		var i UserPost
+		var i GetBookmarkedPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
-			&i.UserID,
-			&i.PostID,
+			&i.Note,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			pq.Array(&i.Tags),
 		); err != nil {
 			return nil, err
 		}
@@ -79,3 +181,109 @@ func (q *Queries) GetBookmarkedPostsForUser(ctx context.Context, userID uuid.UUI
 	}
 	return items, nil
 }
+
+const mergeBookmarkNotes = `-- name: MergeBookmarkNotes :exec
+UPDATE user_posts target
+SET note = source.note
+FROM user_posts source
+JOIN posts src ON src.id = source.post_id
+JOIN posts dst ON dst.guid = src.guid
+WHERE target.post_id = dst.id
+AND target.user_id = source.user_id
+AND src.feed_id = $1
+AND dst.feed_id = $2
+AND target.note IS NULL
+AND source.note IS NOT NULL
+`
+
+type MergeBookmarkNotesParams struct {
+	FromFeedID uuid.UUID
+	ToFeedID   uuid.UUID
+}
+
+func (q *Queries) MergeBookmarkNotes(ctx context.Context, arg MergeBookmarkNotesParams) error {
+	_, err := q.db.ExecContext(ctx, mergeBookmarkNotes, arg.FromFeedID, arg.ToFeedID)
+	return err
+}
+
+const mergeBookmarkTags = `-- name: MergeBookmarkTags :exec
+INSERT INTO bookmark_tags (bookmark_id, tag)
+SELECT target.id, bookmark_tags.tag
+FROM bookmark_tags
+JOIN user_posts source ON source.id = bookmark_tags.bookmark_id
+JOIN posts src ON src.id = source.post_id
+JOIN posts dst ON dst.guid = src.guid
+JOIN user_posts target ON target.post_id = dst.id AND target.user_id = source.user_id
+WHERE src.feed_id = $1
+AND dst.feed_id = $2
+ON CONFLICT DO NOTHING
+`
+
+type MergeBookmarkTagsParams struct {
+	FromFeedID uuid.UUID
+	ToFeedID   uuid.UUID
+}
+
+func (q *Queries) MergeBookmarkTags(ctx context.Context, arg MergeBookmarkTagsParams) error {
+	_, err := q.db.ExecContext(ctx, mergeBookmarkTags, arg.FromFeedID, arg.ToFeedID)
+	return err
+}
+
+const moveBookmarks = `-- name: MoveBookmarks :exec
+UPDATE user_posts
+SET post_id = dst.id
+FROM posts src
+JOIN posts dst ON dst.guid = src.guid
+WHERE user_posts.post_id = src.id
+AND src.feed_id = $1
+AND dst.feed_id = $2
+AND NOT EXISTS (
+    SELECT 1
+    FROM user_posts other
+    WHERE other.user_id = user_posts.user_id AND other.post_id = dst.id
+)
+`
+
+type MoveBookmarksParams struct {
+	FromFeedID uuid.UUID
+	ToFeedID   uuid.UUID
+}
+
+func (q *Queries) MoveBookmarks(ctx context.Context, arg MoveBookmarksParams) error {
+	_, err := q.db.ExecContext(ctx, moveBookmarks, arg.FromFeedID, arg.ToFeedID)
+	return err
+}
+
+const removeBookmarkTag = `-- name: RemoveBookmarkTag :execrows
+DELETE FROM bookmark_tags
+WHERE bookmark_id = $1 AND tag = $2
+`
+
+type RemoveBookmarkTagParams struct {
+	BookmarkID uuid.UUID
+	Tag        string
+}
+
+func (q *Queries) RemoveBookmarkTag(ctx context.Context, arg RemoveBookmarkTagParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeBookmarkTag, arg.BookmarkID, arg.Tag)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setBookmarkNote = `-- name: SetBookmarkNote :exec
+UPDATE user_posts
+SET note = $2
+WHERE id = $1
+`
+
+type SetBookmarkNoteParams struct {
+	ID   uuid.UUID
+	Note sql.NullString
+}
+
+func (q *Queries) SetBookmarkNote(ctx context.Context, arg SetBookmarkNoteParams) error {
+	_, err := q.db.ExecContext(ctx, setBookmarkNote, arg.ID, arg.Note)
+	return err
+}
@@ -72,6 +72,18 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 	return i, err
 }
 
+const demoteSuperUsers = `-- name: DemoteSuperUsers :exec
+UPDATE users
+SET is_superuser = FALSE
+WHERE is_superuser = TRUE
+AND id <> $1
+`
+
+func (q *Queries) DemoteSuperUsers(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, demoteSuperUsers, id)
+	return err
+}
+
 const getUser = `-- name: GetUser :one
 SELECT id, created_at, updated_at, name, hashed_password, is_superuser FROM users 
 WHERE name = $1
@@ -0,0 +1,112 @@
+package rss
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type AtomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Link     []AtomLink  `xml:"link"`
+	Entry    []AtomEntry `xml:"entry"`
+	Syndication
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Link      []AtomLink `xml:"link"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   AtomText   `xml:"summary"`
+	Content   AtomText   `xml:"content"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t *AtomText) value() string {
+	// xhtml content is a nested <div>, not an escaped string
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+
+	return strings.TrimSpace(t.Text)
+}
+
+type atomParser struct{}
+
+func (atomParser) Name() string {
+	return "atom"
+}
+
+func (atomParser) Detect(contentType string, body []byte) bool {
+	return hasRoot(body, atomNamespace, "feed")
+}
+
+func (atomParser) Parse(body []byte) (*Feed, error) {
+	atomStruct := &AtomFeed{}
+	errUnmarshal := xml.Unmarshal(body, atomStruct)
+	if errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+
+	feed := &Feed{
+		Title: atomStruct.Title,
+		Link: alternateLink(atomStruct.Link),
+		Description: atomStruct.Subtitle,
+		Interval: atomStruct.Syndication.interval(),
+	}
+
+	for _, entry := range(atomStruct.Entry) {
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+
+		content := entry.Content.value()
+		description := entry.Summary.value()
+		if description == "" {
+			description = content
+		}
+
+		feed.Entries = append(feed.Entries, Entry{
+			ID: entry.ID,
+			Title: entry.Title,
+			Link: alternateLink(entry.Link),
+			Description: description,
+			Content: content,
+			Published: entryTime(entry.Title, pubDate),
+		})
+	}
+
+	return feed, nil
+}
+
+func alternateLink(links []AtomLink) string {
+	// a link without 'rel' is an alternate link by definition (RFC 4287, 4.2.7.2)
+	for _, link := range(links) {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+
+	if len(links) > 0 {
+		return links[0].Href
+	}
+
+	return ""
+}
@@ -0,0 +1,94 @@
+package rss
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/niccolot/BlogAggregator/internal/database"
+	"github.com/niccolot/BlogAggregator/internal/state"
+)
+
+const (
+	// feeds failing this many times in a row are disabled
+	MaxConsecutiveFailures = 10
+	baseBackoff = time.Minute
+	maxBackoff = 24 * time.Hour
+)
+
+func backoffDelay(failures int32) time.Duration {
+	/*
+	* @brief exponential backoff: 1m, 2m, 4m, ... capped at 24h
+	*/
+	delay := baseBackoff
+	for i := int32(1); i < failures; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+
+	return delay
+}
+
+func recordFailure(s *state.State, feed *database.Feed, errFetch error) error {
+	failures := feed.ConsecutiveFailures + 1
+	disabled := failures >= MaxConsecutiveFailures
+	now := time.Now()
+
+	delay := backoffDelay(failures)
+
+	// the server knows better when it will be ready again
+	var httpErr *HTTPError
+	if errors.As(errFetch, &httpErr) && httpErr.RetryAfter > delay {
+		delay = httpErr.RetryAfter
+	}
+
+	// the feed has been removed for good, no point in retrying
+	gone := httpErr != nil && httpErr.Gone()
+	if gone {
+		disabled = true
+	}
+
+	pars := &database.MarkFeedFailedParams{
+		ID: feed.ID,
+		LastError: sql.NullString{String: errFetch.Error(), Valid: true},
+		ConsecutiveFailures: failures,
+		NextFetchAt: sql.NullTime{Time: now.Add(delay), Valid: true},
+		Disabled: disabled,
+		UpdatedAt: now,
+	}
+
+	// the fetch context may be already expired at this point
+	errMark := s.Db.MarkFeedFailed(context.Background(), *pars)
+	if errMark != nil {
+		return fmt.Errorf("%v (failed to record failure: %v)", errFetch, errMark)
+	}
+
+	if gone {
+		return fmt.Errorf("%v (feed is gone, disabled)", errFetch)
+	}
+
+	if disabled {
+		return fmt.Errorf("%v (feed disabled after %d consecutive failures)", errFetch, failures)
+	}
+
+	return fmt.Errorf("%v (failure %d, retrying at %s)",
+		errFetch, failures, pars.NextFetchAt.Time.Format(time.DateTime))
+}
+
+func postponeFeed(s *state.State, feed *database.Feed, errBlocked *HostBlockedError) error {
+	pars := &database.PostponeFeedParams{
+		ID: feed.ID,
+		NextFetchAt: sql.NullTime{Time: errBlocked.Until, Valid: true},
+	}
+
+	errPostpone := s.Db.PostponeFeed(context.Background(), *pars)
+	if errPostpone != nil {
+		return fmt.Errorf("%v (failed to postpone feed: %v)", errBlocked, errPostpone)
+	}
+
+	return fmt.Errorf("%v (feed postponed)", errBlocked)
+}
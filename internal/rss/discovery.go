@@ -0,0 +1,169 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// a feed found while looking for feeds in a web page
+type Candidate struct {
+	URL string
+	Title string
+	Type string
+	Feed *Feed // already parsed feed, nil if only linked by the page
+}
+
+var feedMimeTypes = map[string]bool{
+	"application/rss+xml": true,
+	"application/atom+xml": true,
+	"application/feed+json": true,
+	"application/rdf+xml": true,
+}
+
+// tried, in order, when a page does not advertise its feeds
+var commonFeedPaths = []string{
+	"/feed",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.xml",
+	"/index.xml",
+	"/feed.json",
+	"/rss",
+}
+
+var (
+	linkTagRegex = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+	attrRegex = regexp.MustCompile(`(?s)([a-zA-Z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+func Discover(ctx context.Context, pageURL string) ([]Candidate, error) {
+	/*
+	* @brief returns the feeds available at pageURL: the url itself if it
+	* is a feed, else the feeds linked by the html page or, as a last
+	* resort, the ones found at the most common feed paths of the site
+	*/
+	body, contentType, finalURL, err := getPage(ctx, pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if feed, errParse := ParseFeed(contentType, body); errParse == nil {
+		return []Candidate{{URL: pageURL, Title: feed.Title, Type: feed.Format, Feed: feed}}, nil
+	}
+
+	candidates := feedLinks(body, finalURL)
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	base, errURL := url.Parse(finalURL)
+	if errURL != nil {
+		return nil, errURL
+	}
+
+	for _, path := range(commonFeedPaths) {
+		probeURL := base.ResolveReference(&url.URL{Path: path}).String()
+
+		probeBody, probeType, _, errProbe := getPage(ctx, probeURL)
+		if errProbe != nil {
+			continue
+		}
+
+		// the common paths are often aliases of the same feed, the first one is enough
+		if feed, errParse := ParseFeed(probeType, probeBody); errParse == nil {
+			return []Candidate{{URL: probeURL, Title: feed.Title, Type: feed.Format, Feed: feed}}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no feed found at '%s'", pageURL)
+}
+
+func feedLinks(body []byte, pageURL string) []Candidate {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []Candidate
+	seen := map[string]bool{}
+
+	for _, tag := range(linkTagRegex.FindAll(body, -1)) {
+		attrs := map[string]string{}
+		for _, match := range(attrRegex.FindAllSubmatch(tag, -1)) {
+			value := string(match[2]) + string(match[3]) + string(match[4])
+			attrs[strings.ToLower(string(match[1]))] = html.UnescapeString(value)
+		}
+
+		if !hasToken(attrs["rel"], "alternate") {
+			continue
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(attrs["type"])
+		if !feedMimeTypes[mediaType] || attrs["href"] == "" {
+			continue
+		}
+
+		href, errHref := url.Parse(strings.TrimSpace(attrs["href"]))
+		if errHref != nil {
+			continue
+		}
+
+		feedURL := base.ResolveReference(href).String()
+		if seen[feedURL] {
+			continue
+		}
+		seen[feedURL] = true
+
+		candidates = append(candidates, Candidate{
+			URL: feedURL,
+			Title: attrs["title"],
+			Type: mediaType,
+		})
+	}
+
+	return candidates
+}
+
+func hasToken(list string, token string) bool {
+	for _, t := range(strings.Fields(list)) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func getPage(ctx context.Context, pageURL string) (body []byte, contentType string, finalURL string, err error) {
+	req, errReq := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if errReq != nil {
+		return nil, "", "", errReq
+	}
+
+	req.Header.Add("User-Agent", "gator")
+	req.Header.Add("Accept-Encoding", "gzip, deflate")
+
+	resp, errResp := httpClient.Do(req)
+	if errResp != nil {
+		return nil, "", "", errResp
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, "", "", newHTTPError(resp)
+	}
+
+	body, errRead := readBody(resp, DefaultMaxFeedSize)
+	if errRead != nil {
+		return nil, "", "", errRead
+	}
+
+	return body, resp.Header.Get("Content-Type"), resp.Request.URL.String(), nil
+}
@@ -0,0 +1,131 @@
+package rss
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// used when the config does not set a maximum feed size
+const DefaultMaxFeedSize = int64(10e+6) // 10MB
+
+// shared by all fetches so that connections are reused between ticks
+var httpClient = &http.Client{
+	Timeout: time.Minute,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns: 100,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout: 90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		// decompression is done by hand to also support deflate
+		DisableCompression: true,
+	},
+}
+
+type HTTPError struct {
+	URL string
+	StatusCode int
+	RetryAfter time.Duration // zero if the server did not send a valid Retry-After
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("'%s' responded with status %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+func (e *HTTPError) TooManyRequests() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+func (e *HTTPError) Unavailable() bool {
+	return e.StatusCode == http.StatusServiceUnavailable
+}
+
+func (e *HTTPError) Gone() bool {
+	return e.StatusCode == http.StatusGone
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	return &HTTPError{
+		URL: resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func parseRetryAfter(value string) time.Duration {
+	// either a number of seconds or an http date
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
+
+func readBody(resp *http.Response, maxSize int64) ([]byte, error) {
+	/*
+	* @brief reads the (possibly compressed) response body, failing if
+	* the decompressed content is bigger than maxSize bytes
+	*/
+	var reader io.Reader = resp.Body
+
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress gzip body: %v", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "deflate":
+		// should be zlib wrapped, but some servers send raw deflate
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		if err != nil {
+			return nil, err
+		}
+		zlibReader, err := zlib.NewReader(bytes.NewReader(raw))
+		if err == nil {
+			defer zlibReader.Close()
+			reader = zlibReader
+		} else {
+			reader = flate.NewReader(bytes.NewReader(raw))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding '%s'", resp.Header.Get("Content-Encoding"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > maxSize {
+		return nil, fmt.Errorf("feed is bigger than the maximum allowed size of %d bytes", maxSize)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,122 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            jsonFeedID `json:"id"`
+	URL           string     `json:"url"`
+	ExternalURL   string     `json:"external_url"`
+	Title         string     `json:"title"`
+	ContentText   string     `json:"content_text"`
+	ContentHTML   string     `json:"content_html"`
+	Summary       string     `json:"summary"`
+	DatePublished string     `json:"date_published"`
+	DateModified  string     `json:"date_modified"`
+}
+
+// the spec wants a string but plenty of feeds in the wild use numbers
+type jsonFeedID string
+
+func (id *jsonFeedID) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*id = jsonFeedID(str)
+		return nil
+	}
+
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return err
+	}
+
+	*id = jsonFeedID(num.String())
+
+	return nil
+}
+
+type jsonFeedParser struct{}
+
+func (jsonFeedParser) Name() string {
+	return "json"
+}
+
+func (jsonFeedParser) Detect(contentType string, body []byte) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "application/feed+json" {
+		return true
+	}
+
+	// servers often send json feeds as plain json or even text
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+
+	return mediaType == "application/json" || bytes.Contains(trimmed, []byte("jsonfeed.org/version"))
+}
+
+func (jsonFeedParser) Parse(body []byte) (*Feed, error) {
+	jsonStruct := &JSONFeed{}
+	errUnmarshal := json.Unmarshal(body, jsonStruct)
+	if errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+
+	feed := &Feed{
+		Title: jsonStruct.Title,
+		Link: jsonStruct.HomePageURL,
+		Description: jsonStruct.Description,
+	}
+
+	for _, item := range(jsonStruct.Items) {
+		link := item.URL
+		if link == "" {
+			link = item.ExternalURL
+		}
+
+		// ids are often permalinks, good enough when no url is given
+		id := string(item.ID)
+		if link == "" && (strings.HasPrefix(id, "http://") || strings.HasPrefix(id, "https://")) {
+			link = id
+		}
+
+		description := item.ContentText
+		if description == "" {
+			description = item.Summary
+		}
+
+		content := item.ContentHTML
+		if content == "" {
+			content = item.ContentText
+		}
+
+		pubDate := item.DatePublished
+		if pubDate == "" {
+			pubDate = item.DateModified
+		}
+
+		feed.Entries = append(feed.Entries, Entry{
+			ID: id,
+			Title: item.Title,
+			Link: link,
+			Description: description,
+			Content: content,
+			Published: entryTime(item.Title, pubDate),
+		})
+	}
+
+	return feed, nil
+}
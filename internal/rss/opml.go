@@ -0,0 +1,152 @@
+package rss
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Head    OPMLHead      `xml:"head"`
+	Body    []OPMLOutline `xml:"body>outline"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// Subscription is a feed listed in an OPML file
+type Subscription struct {
+	Title   string
+	XMLURL  string
+	HTMLURL string
+	Folders []string
+}
+
+func ParseOPML(r io.Reader) ([]Subscription, error) {
+	opml := &OPML{}
+	errDecode := xml.NewDecoder(r).Decode(opml)
+	if errDecode != nil {
+		return nil, fmt.Errorf("invalid opml file: %v", errDecode)
+	}
+
+	var subs []Subscription
+	index := make(map[string]int) // xmlUrl -> position in subs
+	walkOutlines(opml.Body, "", &subs, index)
+
+	return subs, nil
+}
+
+func walkOutlines(outlines []OPMLOutline, folder string, subs *[]Subscription, index map[string]int) {
+	/*
+	* @brief collects the feeds of an outline tree, outlines without
+	* a xmlUrl are folders and their innermost one is the feed folder.
+	* A feed listed more than once ends up in all of its folders
+	*/
+	for _, outline := range(outlines) {
+		title := strings.TrimSpace(outline.Title)
+		if title == "" {
+			title = strings.TrimSpace(outline.Text)
+		}
+
+		xmlURL := strings.TrimSpace(outline.XMLURL)
+		if xmlURL == "" {
+			walkOutlines(outline.Outlines, title, subs, index)
+			continue
+		}
+
+		i, seen := index[xmlURL]
+		if !seen {
+			i = len(*subs)
+			index[xmlURL] = i
+			*subs = append(*subs, Subscription{
+				Title: title,
+				XMLURL: xmlURL,
+				HTMLURL: strings.TrimSpace(outline.HTMLURL),
+			})
+		}
+
+		sub := &(*subs)[i]
+		if folder != "" && !slices.Contains(sub.Folders, folder) {
+			sub.Folders = append(sub.Folders, folder)
+		}
+	}
+}
+
+func WriteOPML(w io.Writer, title string, subs []Subscription) error {
+	/*
+	* @brief writes the subscriptions as an OPML 2.0 file, feeds in
+	* some folders are nested in a folder outline for each one of them
+	*/
+	opml := &OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title: title,
+			DateCreated: time.Now().Format(time.RFC1123Z),
+		},
+	}
+
+	folders := make(map[string]*OPMLOutline)
+	var folderNames []string
+	var topLevel []OPMLOutline
+	for _, sub := range(subs) {
+		outline := OPMLOutline{
+			Text: sub.Title,
+			Title: sub.Title,
+			Type: "rss",
+			XMLURL: sub.XMLURL,
+			HTMLURL: sub.HTMLURL,
+		}
+
+		if len(sub.Folders) == 0 {
+			topLevel = append(topLevel, outline)
+			continue
+		}
+
+		for _, name := range(sub.Folders) {
+			folder, ok := folders[name]
+			if !ok {
+				folder = &OPMLOutline{Text: name, Title: name}
+				folders[name] = folder
+				folderNames = append(folderNames, name)
+			}
+			folder.Outlines = append(folder.Outlines, outline)
+		}
+	}
+
+	for _, name := range(folderNames) {
+		opml.Body = append(opml.Body, *folders[name])
+	}
+	opml.Body = append(opml.Body, topLevel...)
+
+	_, errHeader := io.WriteString(w, xml.Header)
+	if errHeader != nil {
+		return errHeader
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	errEncode := encoder.Encode(opml)
+	if errEncode != nil {
+		return errEncode
+	}
+
+	_, errNewline := io.WriteString(w, "\n")
+
+	return errNewline
+}
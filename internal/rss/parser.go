@@ -0,0 +1,159 @@
+package rss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// format-agnostic representation of a feed, the only shape the storing code sees
+type Feed struct {
+	Format      string
+	Title       string
+	Link        string
+	Description string
+	Entries     []Entry
+	Interval    time.Duration // polling hint of the publisher (ttl, sy:updatePeriod), 0 if missing
+	SkipHours   []int         // GMT hours
+	SkipDays    []time.Weekday
+}
+
+type Entry struct {
+	ID          string
+	Title       string
+	Link        string
+	Description string
+	Content     string
+	Published   time.Time // zero if the feed does not provide a valid date
+}
+
+type Parser interface {
+	// name of the format, e.g. "rss", "atom"
+	Name() string
+	// tells if the body (and content type, if any) is in the parser's format
+	Detect(contentType string, body []byte) bool
+	Parse(body []byte) (*Feed, error)
+}
+
+/*
+parsers are tried in order, the first one whose Detect
+returns true is used to parse the document
+*/
+var parsers = []Parser{
+	jsonFeedParser{},
+	atomParser{},
+	rdfParser{},
+	rss2Parser{},
+}
+
+func (e *Entry) Key() string {
+	/*
+	* @brief returns the identity of the entry inside its feed: the guid/id
+	* given by the feed, else its link, else a hash of its title and
+	* publication time, which unlike the description are not edited
+	*/
+	if id := strings.TrimSpace(e.ID); id != "" {
+		return id
+	}
+
+	if link := strings.TrimSpace(e.Link); link != "" {
+		return link
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(e.Title))
+	hash.Write([]byte{0})
+	hash.Write([]byte(e.Published.UTC().Format(time.RFC3339)))
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func RegisterParser(p Parser) {
+	parsers = append(parsers, p)
+}
+
+func ParseFeed(contentType string, body []byte) (*Feed, error) {
+	for _, p := range(parsers) {
+		if !p.Detect(contentType, body) {
+			continue
+		}
+
+		feed, err := p.Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s feed: %v", p.Name(), err)
+		}
+
+		feed.Format = p.Name()
+
+		return feed, nil
+	}
+
+	return nil, fmt.Errorf("unsupported feed format")
+}
+
+func rootElement(body []byte) (xml.Name, error) {
+	/*
+	* @brief returns the name of the first element of an xml document,
+	* used to tell apart the different feed formats
+	*/
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, fmt.Errorf("failed to find root element: %v", err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+func hasRoot(body []byte, space string, local string) bool {
+	root, err := rootElement(body)
+	if err != nil {
+		return false
+	}
+
+	return root.Space == space && root.Local == local
+}
+
+func entryTime(title string, timeStr string) time.Time {
+	if timeStr == "" {
+		return time.Time{}
+	}
+
+	t, err := parseTime(timeStr)
+	if err != nil {
+		log.Printf("Warning: couldn't parse time for post '%s': %v\n", title, err)
+	}
+
+	return t
+}
+
+func parseTime(timeStr string) (time.Time, error) {
+	formats := []string{
+		time.RFC1123,
+		time.RFC1123Z,
+		time.RFC3339,
+		time.RFC822,
+		time.RFC822Z,
+		// W3C-DTF profiles used by dc:date
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02",
+		// ...add more formats as needed
+	}
+
+	for _, format := range(formats) {
+		if t, errTime := time.Parse(format, timeStr); errTime == nil {
+			return t, errTime
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time: %s", timeStr)
+}
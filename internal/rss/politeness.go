@@ -0,0 +1,222 @@
+package rss
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/niccolot/BlogAggregator/internal/config"
+)
+
+// used when the config does not set the politeness limits
+const (
+	DefaultHostConcurrency = 2
+	DefaultHostSpacing = time.Second
+	DefaultMaxRequestsPerSecond = 10.0
+)
+
+// Politeness limits the load put on the servers hosting the feeds
+type Politeness struct {
+	HostConcurrency      int           // concurrent requests to the same host
+	HostSpacing          time.Duration // minimum time between two requests to the same host
+	MaxRequestsPerSecond float64       // requests to all the hosts together
+}
+
+// HostBlockedError is returned without sending the request when the host
+// asked, with a Retry-After, to be left alone until a later time
+type HostBlockedError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *HostBlockedError) Error() string {
+	return fmt.Sprintf("host '%s' asked to wait until %s", e.Host, e.Until.Format(time.DateTime))
+}
+
+type hostState struct {
+	inFlight     int
+	freed        chan struct{} // closed, and replaced, when a request is done
+	nextRequest  time.Time
+	blockedUntil time.Time
+}
+
+type hostLimiter struct {
+	mu          sync.Mutex
+	limits      Politeness
+	hosts       map[string]*hostState
+	nextRequest time.Time // global requests per second ceiling
+}
+
+// shared by all fetches, like httpClient
+var hostLimits = newHostLimiter(Politeness{
+	HostConcurrency: DefaultHostConcurrency,
+	HostSpacing: DefaultHostSpacing,
+	MaxRequestsPerSecond: DefaultMaxRequestsPerSecond,
+})
+
+func newHostLimiter(limits Politeness) *hostLimiter {
+	return &hostLimiter{
+		limits: limits,
+		hosts: make(map[string]*hostState),
+	}
+}
+
+func PolitenessFromConfig(cfg *config.Config) (*Politeness, error) {
+	/*
+	* @brief politeness limits from the 'host_concurrency', 'host_spacing'
+	* and 'max_requests_per_second' config fields or the defaults
+	*/
+	limits := &Politeness{
+		HostConcurrency: DefaultHostConcurrency,
+		HostSpacing: DefaultHostSpacing,
+		MaxRequestsPerSecond: DefaultMaxRequestsPerSecond,
+	}
+
+	if cfg == nil {
+		return limits, nil
+	}
+
+	if cfg.HostConcurrency < 0 {
+		return nil, fmt.Errorf("invalid host_concurrency %d in config", cfg.HostConcurrency)
+	}
+	if cfg.HostConcurrency > 0 {
+		limits.HostConcurrency = cfg.HostConcurrency
+	}
+
+	if cfg.HostSpacing != "" {
+		spacing, err := time.ParseDuration(cfg.HostSpacing)
+		if err != nil || spacing < 0 {
+			return nil, fmt.Errorf("invalid host_spacing '%s' in config", cfg.HostSpacing)
+		}
+		limits.HostSpacing = spacing
+	}
+
+	if cfg.MaxRequestsPerSecond < 0 {
+		return nil, fmt.Errorf("invalid max_requests_per_second %g in config", cfg.MaxRequestsPerSecond)
+	}
+	if cfg.MaxRequestsPerSecond > 0 {
+		limits.MaxRequestsPerSecond = cfg.MaxRequestsPerSecond
+	}
+
+	return limits, nil
+}
+
+func SetPoliteness(limits *Politeness) {
+	/*
+	* @brief replaces the limits of the following requests, requests
+	* in flight over the new limits are let finish
+	*/
+	hostLimits.mu.Lock()
+	defer hostLimits.mu.Unlock()
+
+	hostLimits.limits = *limits
+}
+
+func hostKey(rawHost string) string {
+	// each host name has its own limits, subdomains are often served separately
+	return strings.ToLower(strings.TrimSuffix(rawHost, "."))
+}
+
+func (l *hostLimiter) host(key string) *hostState {
+	// l.mu must be held
+	host, ok := l.hosts[key]
+	if !ok {
+		host = &hostState{freed: make(chan struct{})}
+		l.hosts[key] = host
+	}
+
+	return host
+}
+
+func (l *hostLimiter) acquire(ctx context.Context, rawHost string) (release func(), err error) {
+	/*
+	* @brief waits for a free slot of the host and for the spacing of the
+	* host and the global ceiling to allow a new request. Fails right away
+	* if the host is blocked by a Retry-After, waiting for it would only
+	* hold the worker
+	*/
+	key := hostKey(rawHost)
+
+	l.mu.Lock()
+	host := l.host(key)
+	for host.inFlight >= l.limits.HostConcurrency {
+		if time.Now().Before(host.blockedUntil) {
+			break
+		}
+
+		freed := host.freed
+		l.mu.Unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		l.mu.Lock()
+	}
+
+	if blockedUntil := host.blockedUntil; time.Now().Before(blockedUntil) {
+		l.mu.Unlock()
+		return nil, &HostBlockedError{Host: key, Until: blockedUntil}
+	}
+
+	host.inFlight++
+	l.mu.Unlock()
+
+	release = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		host.inFlight--
+		close(host.freed)
+		host.freed = make(chan struct{})
+	}
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if blockedUntil := host.blockedUntil; now.Before(blockedUntil) {
+			l.mu.Unlock()
+			release()
+			return nil, &HostBlockedError{Host: key, Until: blockedUntil}
+		}
+
+		wait := max(host.nextRequest.Sub(now), l.nextRequest.Sub(now))
+		if wait <= 0 {
+			host.nextRequest = now.Add(l.limits.HostSpacing)
+			l.nextRequest = now.Add(time.Duration(float64(time.Second) / l.limits.MaxRequestsPerSecond))
+			l.mu.Unlock()
+			return release, nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (l *hostLimiter) block(rawHost string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	host := l.host(hostKey(rawHost))
+	if until.After(host.blockedUntil) {
+		host.blockedUntil = until
+	}
+}
+
+func blocksHost(err *HTTPError) bool {
+	// only overloaded servers saying when to come back block the whole host
+	tooBusy := err.TooManyRequests() || err.Unavailable()
+
+	return tooBusy && err.RetryAfter > 0
+}
@@ -0,0 +1,62 @@
+package rss
+
+import "encoding/xml"
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+type RDFFeed struct {
+	XMLName xml.Name `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# RDF"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Syndication
+	} `xml:"channel"`
+	// in rss 1.0 the items are siblings of the channel, not children
+	Item []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+}
+
+type rdfParser struct{}
+
+func (rdfParser) Name() string {
+	return "rdf"
+}
+
+func (rdfParser) Detect(contentType string, body []byte) bool {
+	return hasRoot(body, rdfNamespace, "RDF")
+}
+
+func (rdfParser) Parse(body []byte) (*Feed, error) {
+	rdfStruct := &RDFFeed{}
+	errUnmarshal := xml.Unmarshal(body, rdfStruct)
+	if errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+
+	feed := &Feed{
+		Title: rdfStruct.Channel.Title,
+		Link: rdfStruct.Channel.Link,
+		Description: rdfStruct.Channel.Description,
+		Interval: rdfStruct.Channel.Syndication.interval(),
+	}
+
+	for _, item := range(rdfStruct.Item) {
+		feed.Entries = append(feed.Entries, Entry{
+			ID: item.About,
+			Title: item.Title,
+			Link: item.Link,
+			Description: item.Description,
+			Published: entryTime(item.Title, item.Date),
+		})
+	}
+
+	return feed, nil
+}
@@ -0,0 +1,168 @@
+package rss
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/niccolot/BlogAggregator/internal/database"
+	"github.com/niccolot/BlogAggregator/internal/state"
+)
+
+// fetches in a row ending on the same permanent redirect before the feed url is updated
+const RedirectStableFetches = 3
+
+func permanentRedirect(resp *http.Response) string {
+	/*
+	* @brief returns the final url if the request went through one
+	* or more redirects, all of them permanent (301/308), else ""
+	*/
+	req := resp.Request
+	if req.Response == nil {
+		return ""
+	}
+
+	for r := req; r.Response != nil; r = r.Response.Request {
+		status := r.Response.StatusCode
+		if status != http.StatusMovedPermanently && status != http.StatusPermanentRedirect {
+			return ""
+		}
+	}
+
+	return req.URL.String()
+}
+
+func trackRedirect(s *state.State, ctx context.Context, feed *database.Feed, target string) error {
+	if target == "" || target == feed.Url {
+		if feed.RedirectCount == 0 {
+			return nil
+		}
+
+		// redirect went away before becoming stable
+		return s.Db.SetFeedRedirect(ctx, database.SetFeedRedirectParams{ID: feed.ID})
+	}
+
+	count := int32(1)
+	if feed.RedirectUrl.String == target {
+		count = feed.RedirectCount + 1
+	}
+
+	if count < RedirectStableFetches {
+		pars := &database.SetFeedRedirectParams{
+			ID: feed.ID,
+			RedirectUrl: sql.NullString{String: target, Valid: true},
+			RedirectCount: count,
+		}
+
+		return s.Db.SetFeedRedirect(ctx, *pars)
+	}
+
+	return moveFeed(s, ctx, feed, target)
+}
+
+func moveFeed(s *state.State, ctx context.Context, feed *database.Feed, target string) error {
+	/*
+	* @brief points the feed to its new url. If another feed already
+	* uses that url, follows and posts are merged into it and the old
+	* feed is deleted, as the unique_url constraint forbids duplicates
+	*/
+	existing, errExisting := s.Db.GetFeedFromURL(ctx, target)
+	if errExisting == sql.ErrNoRows {
+		pars := &database.UpdateFeedURLParams{
+			ID: feed.ID,
+			Url: target,
+			UpdatedAt: time.Now(),
+		}
+
+		errUpdate := s.Db.UpdateFeedURL(ctx, *pars)
+		if errUpdate != nil {
+			return fmt.Errorf("failed to update feed url to '%s': %v", target, errUpdate)
+		}
+
+		log.Printf("Feed '%s' permanently moved to '%s'", feed.Url, target)
+
+		feed.Url = target
+		feed.RedirectUrl = sql.NullString{}
+		feed.RedirectCount = 0
+
+		return nil
+	}
+
+	if errExisting != nil {
+		return fmt.Errorf("failed to look for feed '%s': %v", target, errExisting)
+	}
+
+	followsPars := &database.MoveFeedFollowsParams{
+		ToFeedID: existing.ID,
+		FromFeedID: feed.ID,
+	}
+
+	postsPars := &database.MovePostsParams{
+		ToFeedID: existing.ID,
+		FromFeedID: feed.ID,
+	}
+
+	// bookmarks, read marks and folders follow the posts and follows kept by the target feed
+	mergePars := &database.MoveBookmarksParams{
+		FromFeedID: feed.ID,
+		ToFeedID: existing.ID,
+	}
+
+	errMerge := s.WithTx(ctx, func(q *database.Queries) error {
+		errTags := q.MergeBookmarkTags(ctx, database.MergeBookmarkTagsParams(*mergePars))
+		if errTags != nil {
+			return fmt.Errorf("failed to move bookmark tags to feed '%s': %v", existing.Name, errTags)
+		}
+
+		errNotes := q.MergeBookmarkNotes(ctx, database.MergeBookmarkNotesParams(*mergePars))
+		if errNotes != nil {
+			return fmt.Errorf("failed to move bookmark notes to feed '%s': %v", existing.Name, errNotes)
+		}
+
+		errBookmarks := q.MoveBookmarks(ctx, *mergePars)
+		if errBookmarks != nil {
+			return fmt.Errorf("failed to move bookmarks to feed '%s': %v", existing.Name, errBookmarks)
+		}
+
+		errReads := q.MovePostReads(ctx, database.MovePostReadsParams(*mergePars))
+		if errReads != nil {
+			return fmt.Errorf("failed to move read posts to feed '%s': %v", existing.Name, errReads)
+		}
+
+		// follows of users following both feeds are deleted with the old feed
+		errFolders := q.MergeFolderFeeds(ctx, database.MergeFolderFeedsParams(*mergePars))
+		if errFolders != nil {
+			return fmt.Errorf("failed to move folders to feed '%s': %v", existing.Name, errFolders)
+		}
+
+		errFollows := q.MoveFeedFollows(ctx, *followsPars)
+		if errFollows != nil {
+			return fmt.Errorf("failed to move follows to feed '%s': %v", existing.Name, errFollows)
+		}
+
+		// the posts left behind are the duplicates, deleted with the feed
+		errPosts := q.MovePosts(ctx, *postsPars)
+		if errPosts != nil {
+			return fmt.Errorf("failed to move posts to feed '%s': %v", existing.Name, errPosts)
+		}
+
+		errDelete := q.DeleteFeed(ctx, feed.ID)
+		if errDelete != nil {
+			return fmt.Errorf("failed to delete merged feed '%s': %v", feed.Name, errDelete)
+		}
+
+		return nil
+	})
+	if errMerge != nil {
+		return errMerge
+	}
+
+	log.Printf("Feed '%s' permanently moved to '%s', merged into '%s'", feed.Url, target, existing.Name)
+
+	*feed = existing
+
+	return nil
+}
@@ -3,10 +3,8 @@ package rss
 import (
 	"context"
 	"database/sql"
-	"encoding/xml"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -16,56 +14,144 @@ import (
 	"github.com/niccolot/BlogAggregator/internal/state"
 )
 
-type RSSFeed struct {
-	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
-	} `xml:"channel"`
+type fetchPars struct {
+	url string
+	etag string
+	lastModified string
+	maxSize int64
+	timeout time.Duration // of the request, counted once the host lets it go, 0 for none
 }
 
-type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+type fetchResult struct {
+	feed *Feed // nil when the server answered 304 Not Modified
+	notModified bool
+	etag string
+	lastModified string
+	permanentURL string // final url, if only permanent redirects were followed
+	maxAge time.Duration // Cache-Control max-age, 0 if missing
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	req, errReq := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+func fetchFeed(ctx context.Context, pars *fetchPars) (*fetchResult, error) {
+	req, errReq := http.NewRequestWithContext(ctx, "GET", pars.url, nil)
 	if errReq != nil {
 		return nil, errReq
 	}
 
 	req.Header.Add("User-Agent", "gator")
+	req.Header.Add("Accept-Encoding", "gzip, deflate")
 
-	client := &http.Client{}
-	resp, errResp := client.Do(req)
+	// conditional request, the server can skip the body if nothing changed
+	if pars.etag != "" {
+		req.Header.Add("If-None-Match", pars.etag)
+	}
+	if pars.lastModified != "" {
+		req.Header.Add("If-Modified-Since", pars.lastModified)
+	}
+
+	// the slot of the host is kept until the body has been read
+	release, errAcquire := hostLimits.acquire(ctx, req.URL.Hostname())
+	if errAcquire != nil {
+		return nil, errAcquire
+	}
+	defer release()
+
+	// waiting for the host must not eat the time of the request
+	if pars.timeout > 0 {
+		ctxWithTimeout, cancel := context.WithTimeout(ctx, pars.timeout)
+		defer cancel()
+		req = req.WithContext(ctxWithTimeout)
+	}
+
+	resp, errResp := httpClient.Do(req)
 	if errResp != nil {
 		return nil, errResp
 	}
 
-	body, errRead := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	result := &fetchResult{
+		etag: resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		permanentURL: permanentRedirect(resp),
+		maxAge: maxAge(resp),
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		result.notModified = true
+		return result, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		httpErr := newHTTPError(resp)
+		if blocksHost(httpErr) {
+			// the other feeds of the host wait as well
+			hostLimits.block(req.URL.Hostname(), time.Now().Add(httpErr.RetryAfter))
+		}
+		return nil, httpErr
+	}
+
+	maxSize := pars.maxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFeedSize
+	}
+
+	body, errRead := readBody(resp, maxSize)
 	if errRead != nil {
 		return nil, errRead
 	}
 
-	feedStruct := &RSSFeed{}
-	errUnmarshal := xml.Unmarshal(body, feedStruct)
-	if errUnmarshal != nil {
-		return nil, errUnmarshal
+	feed, errParse := ParseFeed(resp.Header.Get("Content-Type"), body)
+	if errParse != nil {
+		return nil, errParse
 	}
-	
-	return feedStruct, nil
+
+	result.feed = feed
+
+	return result, nil
 }
 
-func FetchAndStoreFeed(s *state.State, feedToFetch *database.Feed, ctx context.Context) error {
+func Preview(ctx context.Context, feedURL string) (*Feed, error) {
+	/*
+	* @brief fetches and parses a feed without storing anything,
+	* used to validate feeds before saving them
+	*/
+	result, err := fetchFeed(ctx, &fetchPars{url: feedURL})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.feed, nil
+}
+
+func FetchAndStoreFeed(s *state.State, feedToFetch *database.Feed, ctx context.Context, timeout time.Duration) error {
 	select {
 	case <- ctx.Done():
-		return fmt.Errorf("warning: fetch time exceeded time between requests, timeout")
+		return fmt.Errorf("warning: fetch cancelled before starting: %v", ctx.Err())
 	default:
-		feed, err := fetchFeed(ctx, feedToFetch.Url)
+		pars := &fetchPars{
+			url: feedToFetch.Url,
+			etag: feedToFetch.Etag.String,
+			lastModified: feedToFetch.LastModified.String,
+			maxSize: s.Cfg.MaxFeedSize,
+			timeout: timeout,
+		}
+
+		result, err := fetchFeed(ctx, pars)
+		if errors.Is(err, context.Canceled) {
+			// the aggregation is stopping, not a feed failure
+			return err
+		}
+		var errBlocked *HostBlockedError
+		if errors.As(err, &errBlocked) {
+			// not a failure of this feed, it is just tried again later
+			return postponeFeed(s, feedToFetch, errBlocked)
+		}
+		if err != nil {
+			return recordFailure(s, feedToFetch, err)
+		}
+
+		// may point feedToFetch to another feed if it has been merged
+		err = trackRedirect(s, ctx, feedToFetch, result.permanentURL)
 		if err != nil {
 			return err
 		}
@@ -76,62 +162,126 @@ func FetchAndStoreFeed(s *state.State, feedToFetch *database.Feed, ctx context.C
 			Valid: true,
 		}
 
-		fetchedPars := &database.MarkFeedFetchedParams{
-			ID: feedToFetch.ID,
-			LastFetchedAt: nullableTime,
+		// nothing changed since the last fetch, the hints saved at the last full fetch are kept
+		if result.notModified {
+			return markFetched(s, ctx, feedToFetch, nullableTime)
+		}
+
+		// the site link is exported along with the feed url
+		siteURL := result.feed.Link
+		if siteURL != "" && siteURL != feedToFetch.SiteUrl.String {
+			siteURLPars := &database.UpdateFeedSiteURLParams{
+				ID: feedToFetch.ID,
+				SiteUrl: sql.NullString{String: siteURL, Valid: true},
+			}
+
+			err = s.Db.UpdateFeedSiteURL(ctx, *siteURLPars)
+			if err != nil {
+				return err
+			}
+		}
+
+		var errsPosts []error
+		for _, entry := range(result.feed.Entries) {
+			errPost := processFeedItem(s, feedToFetch.ID, &entry, nullableTime.Time)
+			if errPost != nil {
+				errsPosts = append(errsPosts, errPost)
+			}
+		}
+
+		// after storing the entries, so that they count in the posting cadence
+		err = updateSchedule(s, ctx, feedToFetch, result.feed, result.maxAge)
+		if err != nil {
+			return err
 		}
 
-		err = s.Db.MarkFeedFetched(ctx, *fetchedPars)
+		err = markFetched(s, ctx, feedToFetch, nullableTime)
 		if err != nil {
 			return err
 		}
 
-		for _, item := range(feed.Channel.Item) {
-			processFeedItem(s, feedToFetch.ID, &item, nullableTime.Time)
+		if len(errsPosts) > 0 {
+			// validators not saved, so next fetch gets the full body again
+			return errors.Join(errsPosts...)
+		}
+
+		validatorsPars := &database.UpdateFeedValidatorsParams{
+			ID: feedToFetch.ID,
+			Etag: sql.NullString{String: result.etag, Valid: result.etag != ""},
+			LastModified: sql.NullString{String: result.lastModified, Valid: result.lastModified != ""},
 		}
 
-		return err
+		return s.Db.UpdateFeedValidators(ctx, *validatorsPars)
 	}	
 }
 
-func processFeedItem(s *state.State, feedID uuid.UUID, item *RSSItem, fetchTime time.Time) {
+func markFetched(s *state.State, ctx context.Context, feed *database.Feed, fetchTime sql.NullTime) error {
+	pars := &database.MarkFeedFetchedParams{
+		ID: feed.ID,
+		LastFetchedAt: fetchTime,
+		NextFetchAt: NextFetchAt(feed, fetchTime.Time),
+	}
+
+	return s.Db.MarkFeedFetched(ctx, *pars)
+}
+
+func processFeedItem(s *state.State, feedID uuid.UUID, entry *Entry, fetchTime time.Time) error {
 	nullableTitle := sql.NullString{
-		String: item.Title,
+		String: entry.Title,
 		Valid: true,
 	}
-	
-	pubTime, errTime := parseTime(item.PubDate)
-	if errTime != nil {
-		log.Printf("Warning: couldn't parse time for post '%s': %v\n", item.Title, errTime)
-	}
 
 	nullPubTime := sql.NullTime{
-		Time: pubTime,
-		Valid: true,
+		Time: entry.Published,
+		Valid: !entry.Published.IsZero(),
 	}
 	
-	nullableDescription := getDescription(item)
+	nullableDescription := getDescription(entry.Description)
 	
-	postPars := &database.CreatePostParams{
+	// re-fetched entries update the stored post instead of duplicating it
+	postPars := &database.UpsertPostParams{
 		ID: uuid.New(),
 		CreatedAt: fetchTime,
 		UpdatedAt: fetchTime,
 		Title: nullableTitle,
-		Url: item.Link,
+		Url: entry.Link,
 		Description: nullableDescription,
 		PublishedAt: nullPubTime,
 		FeedID: feedID,
+		Guid: entry.Key(),
+		Content: sql.NullString{String: entry.Content, Valid: entry.Content != ""},
 	}
 
-	_, errPost := s.Db.CreatePost(context.Background(), *postPars)
+	// posts stored before guids were tracked have their url as guid
+	if entry.Link != "" && postPars.Guid != entry.Link {
+		adoptPars := &database.AdoptLegacyPostGuidParams{
+			Guid: postPars.Guid,
+			FeedID: feedID,
+			Url: entry.Link,
+		}
+
+		errAdopt := s.Db.AdoptLegacyPostGuid(context.Background(), *adoptPars)
+		if errAdopt != nil {
+			return fmt.Errorf("failed to update guid of post '%s': %v", nullableTitle.String, errAdopt)
+		}
+	}
+
+	errPost := s.Db.UpsertPost(context.Background(), *postPars)
 	if errPost != nil {
-		log.Printf("Warning: failed to save post '%s' in the database: %v\n", 
-			nullableTitle.String, errPost)
+		return fmt.Errorf("failed to save post '%s' in the database: %v", nullableTitle.String, errPost)
 	}
+
+	return nil
 }
 
-func ScrapeFeeds(s *state.State, ctx context.Context, batchSize int32) ([]database.Feed, error) {
-	feeds, err := s.Db.GetNextFeedsToFetch(ctx, batchSize)
+func ScrapeFeeds(s *state.State, ctx context.Context, batchSize int32, folderID uuid.NullUUID) ([]database.Feed, error) {
+	pars := &database.GetNextFeedsToFetchParams{
+		NextFetchAt: sql.NullTime{Time: time.Now(), Valid: true},
+		FolderID: folderID,
+		Limit: batchSize,
+	}
+
+	feeds, err := s.Db.GetNextFeedsToFetch(ctx, *pars)
 	if err != nil {
 		return nil, err
 	}
@@ -139,19 +289,19 @@ func ScrapeFeeds(s *state.State, ctx context.Context, batchSize int32) ([]databa
 	return feeds, nil
 }
 
-func getDescription(item *RSSItem) sql.NullString {
+func getDescription(description string) sql.NullString {
 	/*
 	some blogs do not have a proper 'description' rss field
 	but contains just some html, these cases are discarded
 	*/
 	var nullableDescription sql.NullString
-	hasLeftHTML := strings.Contains(item.Description, "<")
-	hasRightHTML := strings.Contains(item.Description, ">")
+	hasLeftHTML := strings.Contains(description, "<")
+	hasRightHTML := strings.Contains(description, ">")
 	hasHTML := hasLeftHTML && hasRightHTML
 
 	if !hasHTML {
 		nullableDescription = sql.NullString{
-			String: item.Description,
+			String: description,
 			Valid: true,
 		}
 	} else {
@@ -160,22 +310,3 @@ func getDescription(item *RSSItem) sql.NullString {
 
 	return nullableDescription
 }
-
-func parseTime(timeStr string) (time.Time, error) {
-	formats := []string{
-		time.RFC1123,
-		time.RFC1123Z,
-		time.RFC3339,
-		time.RFC822,
-		time.RFC822Z,
-		// ...add more formats as needed
-	}
-
-	for _, format := range(formats) {
-		if t, errTime := time.Parse(format, timeStr); errTime == nil {
-			return t, errTime
-		}
-	}
-
-	return time.Time{}, fmt.Errorf("unable to parse time: %s", timeStr)
-}
\ No newline at end of file
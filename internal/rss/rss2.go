@@ -0,0 +1,79 @@
+package rss
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+type RSSFeed struct {
+	Channel struct {
+		Title       string    `xml:"title"`
+		Link        string    `xml:"link"`
+		Description string    `xml:"description"`
+		Item        []RSSItem `xml:"item"`
+		TTL         string    `xml:"ttl"`
+		SkipHours   []string  `xml:"skipHours>hour"`
+		SkipDays    []string  `xml:"skipDays>day"`
+		Syndication
+	} `xml:"channel"`
+}
+
+type RSSItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	PubDate     string  `xml:"pubDate"`
+	Guid        RSSGuid `xml:"guid"`
+}
+
+type RSSGuid struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+type rss2Parser struct{}
+
+func (rss2Parser) Name() string {
+	return "rss"
+}
+
+func (rss2Parser) Detect(contentType string, body []byte) bool {
+	return hasRoot(body, "", "rss")
+}
+
+func (rss2Parser) Parse(body []byte) (*Feed, error) {
+	rssStruct := &RSSFeed{}
+	errUnmarshal := xml.Unmarshal(body, rssStruct)
+	if errUnmarshal != nil {
+		return nil, errUnmarshal
+	}
+
+	feed := &Feed{
+		Title: rssStruct.Channel.Title,
+		Link: rssStruct.Channel.Link,
+		Description: rssStruct.Channel.Description,
+		Interval: max(ttlInterval(rssStruct.Channel.TTL), rssStruct.Channel.Syndication.interval()),
+		SkipHours: parseSkipHours(rssStruct.Channel.SkipHours),
+		SkipDays: parseSkipDays(rssStruct.Channel.SkipDays),
+	}
+
+	for _, item := range(rssStruct.Channel.Item) {
+		guid := strings.TrimSpace(item.Guid.Value)
+
+		// a guid is a permalink unless stated otherwise
+		link := item.Link
+		if link == "" && item.Guid.IsPermaLink != "false" {
+			link = guid
+		}
+
+		feed.Entries = append(feed.Entries, Entry{
+			ID: guid,
+			Title: item.Title,
+			Link: link,
+			Description: item.Description,
+			Published: entryTime(item.Title, item.PubDate),
+		})
+	}
+
+	return feed, nil
+}
@@ -0,0 +1,293 @@
+package rss
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/niccolot/BlogAggregator/internal/config"
+	"github.com/niccolot/BlogAggregator/internal/database"
+	"github.com/niccolot/BlogAggregator/internal/state"
+)
+
+// values of feeds.fetch_interval_source
+const (
+	IntervalDefault = "default"
+	IntervalFeed = "feed"
+	IntervalAdaptive = "adaptive"
+	IntervalUser = "user"
+)
+
+// default bounds of the intervals declared by feeds or adapted to their posts
+const (
+	DefaultMinFetchInterval = time.Minute
+	DefaultMaxFetchInterval = 24 * time.Hour
+	// longest interval accepted by setinterval, stored as int32 seconds
+	MaxUserFetchInterval = 365 * 24 * time.Hour
+)
+
+// the posting cadence is the median gap among the latest posts of a feed
+const (
+	cadencePosts = 20
+	minCadenceGaps = 3
+)
+
+// sy:updatePeriod and sy:updateFrequency, found in rss 1.0, rss 2.0 and atom feeds
+type Syndication struct {
+	UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+func (sy *Syndication) interval() time.Duration {
+	var period time.Duration
+	switch strings.ToLower(strings.TrimSpace(sy.UpdatePeriod)) {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	case "weekly":
+		period = 7 * 24 * time.Hour
+	case "monthly":
+		period = 30 * 24 * time.Hour
+	case "yearly":
+		period = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	// updates per period, 1 if missing
+	frequency, err := strconv.Atoi(strings.TrimSpace(sy.UpdateFrequency))
+	if err != nil || frequency < 1 {
+		frequency = 1
+	}
+
+	return period / time.Duration(frequency)
+}
+
+func ttlInterval(ttl string) time.Duration {
+	// rss 2.0 <ttl> is in minutes
+	minutes, err := strconv.Atoi(strings.TrimSpace(ttl))
+	if err != nil || minutes < 1 {
+		return 0
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+func parseSkipHours(hours []string) []int {
+	var skip []int
+	for _, hour := range(hours) {
+		h, err := strconv.Atoi(strings.TrimSpace(hour))
+		if err != nil || h < 0 || h > 24 {
+			continue
+		}
+
+		// some feeds use 1-24 instead of 0-23
+		skip = append(skip, h%24)
+	}
+
+	return skip
+}
+
+func parseSkipDays(days []string) []time.Weekday {
+	var skip []time.Weekday
+	for _, day := range(days) {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if strings.EqualFold(strings.TrimSpace(day), d.String()) {
+				skip = append(skip, d)
+			}
+		}
+	}
+
+	return skip
+}
+
+func maxAge(resp *http.Response) time.Duration {
+	/*
+	* @brief max-age of the Cache-Control header, 0 if missing
+	* or if the response must not be cached
+	*/
+	var age time.Duration
+	for _, directive := range(strings.Split(resp.Header.Get("Cache-Control"), ",")) {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+
+		value, ok := strings.CutPrefix(directive, "max-age=")
+		if !ok {
+			continue
+		}
+
+		seconds, err := strconv.Atoi(value)
+		if err == nil && seconds > 0 {
+			age = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return age
+}
+
+func FetchIntervalBounds(cfg *config.Config) (minInterval time.Duration, maxInterval time.Duration, err error) {
+	/*
+	* @brief bounds of the automatic fetch intervals, from the
+	* 'min_fetch_interval' and 'max_fetch_interval' config fields
+	* or the defaults
+	*/
+	minInterval = DefaultMinFetchInterval
+	maxInterval = DefaultMaxFetchInterval
+
+	if cfg != nil && cfg.MinFetchInterval != "" {
+		minInterval, err = time.ParseDuration(cfg.MinFetchInterval)
+		if err != nil || minInterval <= 0 {
+			return 0, 0, fmt.Errorf("invalid min_fetch_interval '%s' in config", cfg.MinFetchInterval)
+		}
+	}
+
+	if cfg != nil && cfg.MaxFetchInterval != "" {
+		maxInterval, err = time.ParseDuration(cfg.MaxFetchInterval)
+		if err != nil || maxInterval <= 0 {
+			return 0, 0, fmt.Errorf("invalid max_fetch_interval '%s' in config", cfg.MaxFetchInterval)
+		}
+	}
+
+	if minInterval > maxInterval {
+		return 0, 0, fmt.Errorf("min_fetch_interval (%s) is greater than max_fetch_interval (%s)", minInterval, maxInterval)
+	}
+
+	return minInterval, maxInterval, nil
+}
+
+func postingCadence(s *state.State, ctx context.Context, feedID uuid.UUID) (time.Duration, error) {
+	/*
+	* @brief typical time between two posts of the feed, as the median
+	* gap among its latest stored posts. 0 if there are too few posts
+	* with a publication date to tell
+	*/
+	pars := &database.GetFeedPublishGapsParams{
+		FeedID: feedID,
+		MaxPosts: cadencePosts,
+	}
+
+	gaps, err := s.Db.GetFeedPublishGaps(ctx, *pars)
+	if err != nil {
+		return 0, err
+	}
+
+	if gaps.Gaps < minCadenceGaps {
+		return 0, nil
+	}
+
+	return time.Duration(gaps.MedianGap * float64(time.Second)), nil
+}
+
+func updateSchedule(s *state.State, ctx context.Context, feed *database.Feed, parsed *Feed, cacheMaxAge time.Duration) error {
+	/*
+	* @brief saves the polling hints of a freshly fetched feed. The most
+	* conservative of ttl, sy:updatePeriod and max-age is used, or the
+	* posting cadence of the feed if it has none of them, unless the
+	* interval has been set by the user with setinterval
+	*/
+	minInterval, maxInterval, err := FetchIntervalBounds(s.Cfg)
+	if err != nil {
+		return err
+	}
+
+	source := IntervalDefault
+	interval := max(parsed.Interval, cacheMaxAge)
+	if interval > 0 {
+		source = IntervalFeed
+	}
+
+	// the cadence needs the stored posts, user intervals are not touched
+	if interval == 0 && feed.FetchIntervalSource != IntervalUser {
+		interval, err = postingCadence(s, ctx, feed.ID)
+		if err != nil {
+			return err
+		}
+		if interval > 0 {
+			source = IntervalAdaptive
+		}
+	}
+
+	if interval > 0 {
+		interval = min(max(interval, minInterval), maxInterval)
+	}
+
+	pars := &database.UpdateFeedScheduleParams{
+		ID: feed.ID,
+		FetchInterval: feed.FetchInterval,
+		FetchIntervalSource: feed.FetchIntervalSource,
+		SkipHours: []int32{},
+		SkipDays: []int32{},
+	}
+
+	if feed.FetchIntervalSource != IntervalUser {
+		pars.FetchInterval = sql.NullInt32{Int32: int32(interval.Seconds()), Valid: interval > 0}
+		pars.FetchIntervalSource = source
+	}
+
+	for _, hour := range(parsed.SkipHours) {
+		pars.SkipHours = append(pars.SkipHours, int32(hour))
+	}
+	for _, day := range(parsed.SkipDays) {
+		pars.SkipDays = append(pars.SkipDays, int32(day))
+	}
+
+	err = s.Db.UpdateFeedSchedule(ctx, *pars)
+	if err != nil {
+		return err
+	}
+
+	feed.FetchInterval = pars.FetchInterval
+	feed.FetchIntervalSource = pars.FetchIntervalSource
+	feed.SkipHours = pars.SkipHours
+	feed.SkipDays = pars.SkipDays
+
+	return nil
+}
+
+func NextFetchAt(feed *database.Feed, fetchTime time.Time) sql.NullTime {
+	/*
+	* @brief next time the feed is due, NULL (i.e. every aggregation
+	* cycle) without an interval. Times in the skipped hours and days
+	* of the feed (in GMT) are moved to the first allowed hour
+	*/
+	next := fetchTime.UTC()
+	if feed.FetchInterval.Valid {
+		next = next.Add(time.Duration(feed.FetchInterval.Int32) * time.Second)
+	}
+
+	// a week of hours at most, in case every hour is skipped
+	for i := 0; i < 7*24 && skipped(feed, next); i++ {
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+
+	if !feed.FetchInterval.Valid && next.Equal(fetchTime.UTC()) {
+		return sql.NullTime{Valid: false}
+	}
+
+	// timestamps are stored without time zone, as local times
+	return sql.NullTime{Time: next.In(fetchTime.Location()), Valid: true}
+}
+
+func skipped(feed *database.Feed, t time.Time) bool {
+	for _, hour := range(feed.SkipHours) {
+		if int(hour) == t.Hour() {
+			return true
+		}
+	}
+
+	for _, day := range(feed.SkipDays) {
+		if time.Weekday(day) == t.Weekday() {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,13 +1,46 @@
 package state
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+
 	"github.com/niccolot/BlogAggregator/internal/config"
 	"github.com/niccolot/BlogAggregator/internal/database"
 )
 
 type State struct {
 	Db *database.Queries
+	Conn *sql.DB
 	Cfg *config.Config
 	Aggregating bool
 	StopAggregation chan(bool)
-}
\ No newline at end of file
+	Output string // format of the listing commands, text|json|csv|tsv
+}
+
+func (s *State) WithTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	/*
+	* @brief runs fn inside a database transaction, fn must use the
+	* given queries. The transaction is committed only if fn succeeds,
+	* otherwise every write done by fn is rolled back
+	*/
+	tx, errTx := s.Conn.BeginTx(ctx, nil)
+	if errTx != nil {
+		return fmt.Errorf("failed to start transaction: %v", errTx)
+	}
+
+	// no-op after a successful commit
+	defer tx.Rollback()
+
+	errFn := fn(s.Db.WithTx(tx))
+	if errFn != nil {
+		return errFn
+	}
+
+	errCommit := tx.Commit()
+	if errCommit != nil {
+		return fmt.Errorf("failed to commit transaction: %v", errCommit)
+	}
+
+	return nil
+}
@@ -2,9 +2,12 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -16,8 +19,9 @@ import (
 )
 
 func main() {
+	// a missing .env is fine when DB_URL comes from the environment (e.g. cron)
 	errEnv := godotenv.Load()
-	if errEnv != nil {
+	if errEnv != nil && os.Getenv("DB_URL") == "" {
 		log.Fatalf(fmt.Sprintf("error loading environment variables: %v", errEnv))
 	}
 
@@ -34,14 +38,27 @@ func main() {
 
 	s := state.State{
 		Db: dbQueries,
+		Conn: db,
 		Cfg: cfg,
 		Aggregating: false,
 		StopAggregation: make(chan bool),
+		Output: commands.OutputText,
+	}
+
+	if cfg != nil && commands.IsOutputFormat(cfg.Output) {
+		s.Output = cfg.Output
 	}
 
 	cmds := commands.Commands{}
 	cmds.Init()
 
+	// 'gator <command> [args]' runs a single command instead of the REPL
+	if len(os.Args) > 1 {
+		exitCode := runOnce(&cmds, &s, os.Args[1:])
+		db.Close()
+		os.Exit(exitCode)
+	}
+
 	line := liner.NewLiner()
 	defer line.Close()
 
@@ -77,4 +94,41 @@ func main() {
 		
 		fmt.Println()
 	}
+}
+
+const (
+	exitSuccess = 0
+	exitFailure = 1 // the command failed
+	exitUsage = 2 // unknown command
+)
+
+func runOnce(cmds *commands.Commands, s *state.State, args []string) int {
+	// global options before the command name, e.g. 'gator --output json feeds'
+	if args[0] == "--output" && len(args) > 2 {
+		cmdArgs := append([]string{}, args[2:]...)
+		args = append(cmdArgs, args[0], args[1])
+	}
+
+	cmd := commands.Command{
+		CmdName: strings.ToLower(args[0]),
+		Args: args[1:],
+	}
+
+	// unlike in the REPL, aggregate runs in the foreground until killed
+	errCmd := cmds.Run(s, cmd)
+	if errors.Is(errCmd, commands.ErrCommandNotFound) {
+		fmt.Fprintln(os.Stderr, errCmd)
+		return exitUsage
+	}
+	if errCmd != nil {
+		fmt.Fprintln(os.Stderr, errCmd)
+		return exitFailure
+	}
+
+	// some text outputs do not end with a newline, structured ones do
+	if s.Output == commands.OutputText && !slices.Contains(args, "--output") {
+		fmt.Println()
+	}
+
+	return exitSuccess
 }
\ No newline at end of file
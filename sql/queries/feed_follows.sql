@@ -28,8 +28,33 @@ CREATE INDEX idx_feed_follows_user_id ON feed_follows(user_id);
 CREATE INDEX idx_user_id_feed_id ON feed_follows (user_id, feed_id);
 
 -- name: GetFeedFollowsForUser :many
-SELECT * from feed_follows
-WHERE user_id = $1;
+SELECT
+    feed_follows.*,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    feeds.site_url AS feed_site_url,
+    COALESCE(
+        array_agg(folders.name ORDER BY folders.name) FILTER (WHERE folders.name IS NOT NULL),
+        '{}'
+    )::text[] AS folders
+FROM feed_follows
+INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+LEFT JOIN folder_feeds ON folder_feeds.feed_follow_id = feed_follows.id
+LEFT JOIN folders ON folders.id = folder_feeds.folder_id
+WHERE feed_follows.user_id = $1
+GROUP BY feed_follows.id, feeds.id
+ORDER BY feeds.name;
+
+-- name: GetFeedFollow :one
+SELECT * FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: GetFeedFollowForFeed :one
+SELECT feed_follows.*
+FROM feed_follows
+INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (feeds.url = sqlc.arg(feed) OR feeds.name = sqlc.arg(feed));
 
 -- name: Unfollow :exec
 DELETE FROM feed_follows
@@ -38,4 +63,14 @@ AND feed_id = (
     SELECT id
     FROM feeds
     WHERE url = $2 OR name = $2
+);
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(to_feed_id)
+WHERE feed_id = sqlc.arg(from_feed_id)
+AND user_id NOT IN (
+    SELECT user_id
+    FROM feed_follows
+    WHERE feed_id = sqlc.arg(to_feed_id)
 );
\ No newline at end of file
@@ -23,17 +23,115 @@ WHERE id = $1;
 SELECT * FROM feeds 
 WHERE url = $1;
 
+-- name: GetFeedFromURLOrName :one
+SELECT * FROM feeds
+WHERE url = sqlc.arg(feed) OR name = sqlc.arg(feed)
+ORDER BY url = sqlc.arg(feed) DESC
+LIMIT 1;
+
 -- name: MarkFeedFetched :exec
 UPDATE feeds
 SET last_fetched_at = $2, 
+	updated_at = $2,
+	last_error = NULL,
+	consecutive_failures = 0,
+	next_fetch_at = $3
+WHERE id = $1;
+
+-- name: MarkFeedFailed :exec
+UPDATE feeds
+SET last_error = $2,
+	consecutive_failures = $3,
+	next_fetch_at = $4,
+	disabled = $5,
+	updated_at = $6
+WHERE id = $1;
+
+-- name: GetDisabledFeeds :many
+SELECT * FROM feeds
+WHERE disabled = TRUE
+ORDER BY name;
+
+-- name: EnableFeed :execrows
+UPDATE feeds
+SET disabled = FALSE,
+	last_error = NULL,
+	consecutive_failures = 0,
+	next_fetch_at = NULL,
 	updated_at = $2
+WHERE url = $1 OR name = $1;
+
+-- name: UpdateFeedValidators :exec
+UPDATE feeds
+SET etag = $2,
+	last_modified = $3
+WHERE id = $1;
+
+-- name: SetFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = $2,
+	redirect_count = $3
+WHERE id = $1;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET url = $2,
+	updated_at = $3,
+	redirect_url = NULL,
+	redirect_count = 0
+WHERE id = $1;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
 WHERE id = $1;
 
 -- name: GetNextFeedsToFetch :many
 SELECT feeds.*
-FROM feeds INNER JOIN feed_follows ON feeds.id = feed_follows.feed_id
+FROM feeds
+WHERE feeds.disabled = FALSE
+AND (feeds.next_fetch_at IS NULL OR feeds.next_fetch_at <= sqlc.arg(next_fetch_at))
+AND EXISTS (
+    SELECT 1 FROM feed_follows
+    WHERE feed_follows.feed_id = feeds.id
+    AND (sqlc.narg(folder_id)::uuid IS NULL OR feed_follows.id IN (
+        SELECT feed_follow_id FROM folder_feeds WHERE folder_id = sqlc.narg(folder_id)))
+)
 ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT $1;
+LIMIT sqlc.arg('limit');
 
 -- name: ResetFeeds :exec
-DELETE FROM feeds;
\ No newline at end of file
+DELETE FROM feeds;
+
+-- name: FeedNameExists :one
+SELECT EXISTS (
+    SELECT 1 FROM feeds
+    WHERE name = $1
+);
+
+-- name: UpdateFeedSiteURL :exec
+UPDATE feeds
+SET site_url = $2
+WHERE id = $1;
+
+-- name: UpdateFeedSchedule :exec
+UPDATE feeds
+SET fetch_interval = $2,
+	fetch_interval_source = $3,
+	skip_hours = $4,
+	skip_days = $5
+WHERE id = $1;
+
+-- name: SetFeedInterval :exec
+UPDATE feeds
+SET fetch_interval = sqlc.narg(fetch_interval),
+	fetch_interval_source = sqlc.arg(fetch_interval_source),
+	next_fetch_at = CASE WHEN consecutive_failures = 0 THEN sqlc.narg(next_fetch_at) ELSE next_fetch_at END,
+	etag = CASE WHEN sqlc.arg(fetch_interval_source) = 'user' THEN etag ELSE NULL END,
+	last_modified = CASE WHEN sqlc.arg(fetch_interval_source) = 'user' THEN last_modified ELSE NULL END,
+	updated_at = sqlc.arg(updated_at)
+WHERE id = sqlc.arg(id);
+
+-- name: PostponeFeed :exec
+UPDATE feeds
+SET next_fetch_at = $2
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,75 @@
+-- name: UpsertFolder :one
+INSERT INTO folders (id, created_at, updated_at, user_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = folders.updated_at
+RETURNING *;
+
+-- name: AddFeedToFolder :exec
+INSERT INTO folder_feeds (folder_id, feed_follow_id)
+VALUES (
+    $1,
+    $2
+)
+ON CONFLICT (folder_id, feed_follow_id) DO NOTHING;
+
+-- name: CreateFolder :one
+INSERT INTO folders (id, created_at, updated_at, user_id, name)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetFolder :one
+SELECT * FROM folders
+WHERE user_id = $1 AND name = $2;
+
+-- name: GetFoldersForUser :many
+SELECT
+    folders.id,
+    folders.name,
+    COUNT(DISTINCT folder_feeds.feed_follow_id) AS feeds,
+    COUNT(posts.id) FILTER (WHERE post_reads.post_id IS NULL) AS unread
+FROM folders
+LEFT JOIN folder_feeds ON folder_feeds.folder_id = folders.id
+LEFT JOIN feed_follows ON feed_follows.id = folder_feeds.feed_follow_id
+LEFT JOIN posts ON posts.feed_id = feed_follows.feed_id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id
+    AND post_reads.user_id = folders.user_id
+WHERE folders.user_id = $1
+GROUP BY folders.id, folders.name
+ORDER BY folders.name;
+
+-- name: RenameFolder :execrows
+UPDATE folders
+SET name = sqlc.arg(new_name),
+    updated_at = sqlc.arg(updated_at)
+WHERE user_id = sqlc.arg(user_id) AND name = sqlc.arg(name);
+
+-- name: DeleteFolder :execrows
+DELETE FROM folders
+WHERE user_id = $1 AND name = $2;
+
+-- name: RemoveFeedFromFolder :execrows
+DELETE FROM folder_feeds
+WHERE folder_id = $1 AND feed_follow_id = $2;
+
+-- name: MergeFolderFeeds :exec
+INSERT INTO folder_feeds (folder_id, feed_follow_id)
+SELECT folder_feeds.folder_id, target.id
+FROM folder_feeds
+JOIN feed_follows source ON source.id = folder_feeds.feed_follow_id
+JOIN feed_follows target ON target.user_id = source.user_id
+WHERE source.feed_id = sqlc.arg(from_feed_id)
+AND target.feed_id = sqlc.arg(to_feed_id)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,62 @@
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (post_id, user_id, read_at)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkFeedPostsRead :execrows
+INSERT INTO post_reads (post_id, user_id, read_at)
+SELECT posts.id, sqlc.arg(user_id), sqlc.arg(read_at)
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (feeds.url = sqlc.arg(feed) OR feeds.name = sqlc.arg(feed))
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (post_id, user_id, read_at)
+SELECT posts.id, feed_follows.user_id, $2
+FROM posts
+INNER JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = $1
+AND post_id = $2;
+
+-- name: GetFollowedFeedsWithUnread :many
+SELECT
+    feeds.id,
+    feeds.name,
+    feeds.url,
+    COUNT(posts.id) FILTER (WHERE post_reads.post_id IS NULL) AS unread
+FROM feed_follows
+INNER JOIN feeds ON feeds.id = feed_follows.feed_id
+LEFT JOIN posts ON posts.feed_id = feeds.id
+LEFT JOIN post_reads ON post_reads.post_id = posts.id
+    AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(folder_id)::uuid IS NULL OR feed_follows.id IN (
+    SELECT feed_follow_id FROM folder_feeds WHERE folder_id = sqlc.narg(folder_id)))
+GROUP BY feeds.id, feeds.name, feeds.url
+ORDER BY feeds.name;
+
+-- name: MovePostReads :exec
+UPDATE post_reads
+SET post_id = dst.id
+FROM posts src
+JOIN posts dst ON dst.guid = src.guid
+WHERE post_reads.post_id = src.id
+AND src.feed_id = sqlc.arg(from_feed_id)
+AND dst.feed_id = sqlc.arg(to_feed_id)
+AND NOT EXISTS (
+    SELECT 1
+    FROM post_reads other
+    WHERE other.user_id = post_reads.user_id AND other.post_id = dst.id
+);
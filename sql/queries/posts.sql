@@ -1,13 +1,15 @@
--- name: CreatePost :one
+-- name: UpsertPost :exec
 INSERT INTO posts (
-    id, 
+    id,
     created_at,
     updated_at,
     title,
     url,
     description,
     published_at,
-    feed_id)
+    feed_id,
+    guid,
+    content)
 VALUES (
     $1,
     $2,
@@ -16,8 +18,33 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
-) RETURNING *;
+    $8,
+    $9,
+    $10
+)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    published_at = COALESCE(EXCLUDED.published_at, posts.published_at),
+    updated_at = EXCLUDED.updated_at
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+OR posts.url IS DISTINCT FROM EXCLUDED.url
+OR posts.description IS DISTINCT FROM EXCLUDED.description
+OR posts.content IS DISTINCT FROM EXCLUDED.content;
+
+-- name: AdoptLegacyPostGuid :exec
+UPDATE posts
+SET guid = sqlc.arg(guid)
+WHERE feed_id = sqlc.arg(feed_id)
+AND url = sqlc.arg(url)
+AND guid = url
+AND NOT EXISTS (
+    SELECT 1
+    FROM posts p
+    WHERE p.feed_id = sqlc.arg(feed_id) AND p.guid = sqlc.arg(guid)
+);
 
 CREATE INDEX idx_posts_feed_id ON posts(feed_id);
 
@@ -47,18 +74,96 @@ WHERE id = $1;
 -- name: GetPostsForUser :many
 WITH users_posts AS (
     SELECT feed_follows.feed_id,
-            feeds.name 
+            feeds.name,
+            feeds.url
     FROM feed_follows 
     INNER JOIN feeds ON feed_follows.feed_id = feeds.id 
-    WHERE feed_follows.user_id = $1
+    WHERE feed_follows.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(folder_id)::uuid IS NULL OR feed_follows.id IN (
+        SELECT feed_follow_id FROM folder_feeds WHERE folder_id = sqlc.narg(folder_id)))
 )
 SELECT 
+    posts.id,
     posts.title,
     posts.url,
     posts.published_at,
     posts.description,
-    users_posts.name AS feed_name
+    users_posts.name AS feed_name,
+    (post_reads.post_id IS NOT NULL)::bool AS is_read
 FROM posts
 INNER JOIN users_posts ON users_posts.feed_id = posts.feed_id
-ORDER BY COALESCE(posts.created_at, posts.updated_at) DESC
-LIMIT $2;
+LEFT JOIN post_reads ON post_reads.post_id = posts.id
+    AND post_reads.user_id = sqlc.arg(user_id)
+WHERE (NOT sqlc.arg(unread_only)::bool OR post_reads.post_id IS NULL)
+AND (sqlc.narg(feed)::text IS NULL
+    OR users_posts.name = sqlc.narg(feed)
+    OR users_posts.url = sqlc.narg(feed))
+AND (sqlc.narg(keyword)::text IS NULL
+    OR posts.title ILIKE '%' || sqlc.narg(keyword) || '%'
+    OR posts.description ILIKE '%' || sqlc.narg(keyword) || '%')
+AND (sqlc.narg(since)::timestamp IS NULL
+    OR CASE WHEN sqlc.arg(order_by)::text = 'published'
+        THEN COALESCE(posts.published_at, posts.created_at)
+        ELSE posts.created_at END >= sqlc.narg(since))
+AND (sqlc.narg(until)::timestamp IS NULL
+    OR CASE WHEN sqlc.arg(order_by)::text = 'published'
+        THEN COALESCE(posts.published_at, posts.created_at)
+        ELSE posts.created_at END < sqlc.narg(until))
+ORDER BY CASE WHEN sqlc.arg(order_by)::text = 'published'
+    THEN COALESCE(posts.published_at, posts.created_at)
+    ELSE posts.created_at END DESC,
+    posts.id
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(to_feed_id)
+WHERE feed_id = sqlc.arg(from_feed_id)
+AND guid NOT IN (
+    SELECT guid
+    FROM posts
+    WHERE feed_id = sqlc.arg(to_feed_id)
+);
+
+-- name: SearchPosts :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        COALESCE(posts.title, '') || ' ' || COALESCE(posts.description, '') || ' ' || COALESCE(posts.content, ''),
+        query,
+        'StartSel=<<, StopSel=>>, MaxWords=25, MinWords=10, MaxFragments=2'
+    )::text AS snippet
+FROM posts
+INNER JOIN feeds ON feeds.id = posts.feed_id
+CROSS JOIN websearch_to_tsquery('english', sqlc.arg(query)) AS query
+WHERE posts.search_vector @@ query
+AND (sqlc.arg(scope)::text = 'all'
+    OR (sqlc.arg(scope) = 'following' AND posts.feed_id IN (
+        SELECT feed_id FROM feed_follows WHERE user_id = sqlc.arg(user_id)))
+    OR (sqlc.arg(scope) = 'bookmarks' AND posts.id IN (
+        SELECT post_id FROM user_posts WHERE user_id = sqlc.arg(user_id))))
+ORDER BY rank DESC, posts.published_at DESC NULLS LAST
+LIMIT sqlc.arg('limit');
+
+-- name: GetFeedPublishGaps :one
+SELECT
+    COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY gap), 0)::float8 AS median_gap,
+    COUNT(*) AS gaps
+FROM (
+    SELECT EXTRACT(EPOCH FROM published_at - LAG(published_at) OVER (ORDER BY published_at)) AS gap
+    FROM (
+        SELECT published_at
+        FROM posts
+        WHERE feed_id = sqlc.arg(feed_id) AND published_at IS NOT NULL
+        ORDER BY published_at DESC
+        LIMIT sqlc.arg(max_posts)::int
+    ) recent
+) gaps
+WHERE gap > 0;
\ No newline at end of file
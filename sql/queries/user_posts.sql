@@ -9,5 +9,88 @@ VALUES (
 RETURNING *;
 
 -- name: GetBookmarkedPostsForUser :many
+SELECT
+    user_posts.id,
+    user_posts.created_at,
+    user_posts.note,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    feeds.name AS feed_name,
+    COALESCE(
+        array_agg(bookmark_tags.tag ORDER BY bookmark_tags.tag) FILTER (WHERE bookmark_tags.tag IS NOT NULL),
+        '{}'
+    )::text[] AS tags
+FROM user_posts
+INNER JOIN posts ON posts.id = user_posts.post_id
+INNER JOIN feeds ON feeds.id = posts.feed_id
+LEFT JOIN bookmark_tags ON bookmark_tags.bookmark_id = user_posts.id
+WHERE user_posts.user_id = sqlc.arg(user_id)
+AND (sqlc.narg(tag)::text IS NULL OR user_posts.id IN (
+    SELECT bookmark_id FROM bookmark_tags WHERE tag = sqlc.narg(tag)))
+GROUP BY user_posts.id, posts.id, feeds.name
+ORDER BY user_posts.created_at DESC;
+
+-- name: GetBookmark :one
 SELECT * FROM user_posts
-WHERE user_id = $1;
\ No newline at end of file
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM user_posts
+WHERE user_id = $1 AND post_id = $2;
+
+-- name: SetBookmarkNote :exec
+UPDATE user_posts
+SET note = $2
+WHERE id = $1;
+
+-- name: AddBookmarkTag :exec
+INSERT INTO bookmark_tags (bookmark_id, tag)
+VALUES (
+    $1,
+    $2
+)
+ON CONFLICT (bookmark_id, tag) DO NOTHING;
+
+-- name: RemoveBookmarkTag :execrows
+DELETE FROM bookmark_tags
+WHERE bookmark_id = $1 AND tag = $2;
+
+-- name: MergeBookmarkTags :exec
+INSERT INTO bookmark_tags (bookmark_id, tag)
+SELECT target.id, bookmark_tags.tag
+FROM bookmark_tags
+JOIN user_posts source ON source.id = bookmark_tags.bookmark_id
+JOIN posts src ON src.id = source.post_id
+JOIN posts dst ON dst.guid = src.guid
+JOIN user_posts target ON target.post_id = dst.id AND target.user_id = source.user_id
+WHERE src.feed_id = sqlc.arg(from_feed_id)
+AND dst.feed_id = sqlc.arg(to_feed_id)
+ON CONFLICT DO NOTHING;
+
+-- name: MergeBookmarkNotes :exec
+UPDATE user_posts target
+SET note = source.note
+FROM user_posts source
+JOIN posts src ON src.id = source.post_id
+JOIN posts dst ON dst.guid = src.guid
+WHERE target.post_id = dst.id
+AND target.user_id = source.user_id
+AND src.feed_id = sqlc.arg(from_feed_id)
+AND dst.feed_id = sqlc.arg(to_feed_id)
+AND target.note IS NULL
+AND source.note IS NOT NULL;
+
+-- name: MoveBookmarks :exec
+UPDATE user_posts
+SET post_id = dst.id
+FROM posts src
+JOIN posts dst ON dst.guid = src.guid
+WHERE user_posts.post_id = src.id
+AND src.feed_id = sqlc.arg(from_feed_id)
+AND dst.feed_id = sqlc.arg(to_feed_id)
+AND NOT EXISTS (
+    SELECT 1
+    FROM user_posts other
+    WHERE other.user_id = user_posts.user_id AND other.post_id = dst.id
+);
@@ -31,6 +31,12 @@ UPDATE users
 SET is_superuser = TRUE
 WHERE id = $1;
 
+-- name: DemoteSuperUsers :exec
+UPDATE users
+SET is_superuser = FALSE
+WHERE is_superuser = TRUE
+AND id <> $1;
+
 -- name: ChangePassword :exec
 UPDATE users
 SET hashed_password = $2
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE posts
+ADD COLUMN guid TEXT;
+
+UPDATE posts
+SET guid = url;
+
+-- bookmarks of the duplicated copies are moved to the copy that is kept,
+-- at most one per user, the others would be deleted by the cascade anyway
+WITH survivors AS (
+    SELECT DISTINCT ON (feed_id, guid) id, feed_id, guid
+    FROM posts
+    ORDER BY feed_id, guid, created_at, id
+),
+moves AS (
+    SELECT DISTINCT ON (user_posts.user_id, survivors.id)
+        user_posts.id AS bookmark_id,
+        survivors.id AS post_id
+    FROM user_posts
+    JOIN posts dup ON dup.id = user_posts.post_id
+    JOIN survivors ON survivors.feed_id = dup.feed_id AND survivors.guid = dup.guid
+    WHERE dup.id <> survivors.id
+    AND NOT EXISTS (
+        SELECT 1
+        FROM user_posts other
+        WHERE other.user_id = user_posts.user_id AND other.post_id = survivors.id
+    )
+    ORDER BY user_posts.user_id, survivors.id, user_posts.created_at
+)
+UPDATE user_posts
+SET post_id = moves.post_id
+FROM moves
+WHERE user_posts.id = moves.bookmark_id;
+
+-- keeps only the first stored copy of each duplicated post
+DELETE FROM posts a
+USING posts b
+WHERE a.feed_id = b.feed_id
+AND a.guid = b.guid
+AND (a.created_at, a.id) > (b.created_at, b.id);
+
+ALTER TABLE posts
+ALTER COLUMN guid SET NOT NULL;
+
+ALTER TABLE posts
+ADD CONSTRAINT unique_feed_guid UNIQUE (feed_id, guid);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE posts
+DROP CONSTRAINT unique_feed_guid;
+
+ALTER TABLE posts
+DROP COLUMN guid;
+-- +goose StatementEnd
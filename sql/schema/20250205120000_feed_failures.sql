@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE feeds
+ADD COLUMN last_error TEXT;
+
+ALTER TABLE feeds
+ADD COLUMN consecutive_failures INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE feeds
+ADD COLUMN next_fetch_at TIMESTAMP;
+
+ALTER TABLE feeds
+ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feeds
+DROP COLUMN disabled;
+
+ALTER TABLE feeds
+DROP COLUMN next_fetch_at;
+
+ALTER TABLE feeds
+DROP COLUMN consecutive_failures;
+
+ALTER TABLE feeds
+DROP COLUMN last_error;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE feeds
+ADD COLUMN redirect_url TEXT;
+
+ALTER TABLE feeds
+ADD COLUMN redirect_count INTEGER NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feeds
+DROP COLUMN redirect_count;
+
+ALTER TABLE feeds
+DROP COLUMN redirect_url;
+-- +goose StatementEnd
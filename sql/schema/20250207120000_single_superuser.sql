@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- keeps only the oldest superuser, changesuper never demoted the previous ones
+UPDATE users
+SET is_superuser = FALSE
+WHERE is_superuser = TRUE
+AND id <> (
+    SELECT id
+    FROM users
+    WHERE is_superuser = TRUE
+    ORDER BY created_at
+    LIMIT 1
+);
+
+CREATE UNIQUE INDEX unique_superuser ON users (is_superuser)
+WHERE is_superuser = TRUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX unique_superuser;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_posts
+ADD COLUMN note TEXT;
+
+CREATE TABLE bookmark_tags(
+    bookmark_id UUID NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (bookmark_id, tag),
+    FOREIGN KEY (bookmark_id) REFERENCES user_posts(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE bookmark_tags;
+
+ALTER TABLE user_posts
+DROP COLUMN note;
+-- +goose StatementEnd
@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+-- seconds, NULL fetches the feed at every aggregation cycle
+ALTER TABLE feeds
+ADD COLUMN fetch_interval INTEGER;
+
+-- 'default', 'feed' (ttl, sy:updatePeriod, Cache-Control) or 'user' (setinterval)
+ALTER TABLE feeds
+ADD COLUMN fetch_interval_source TEXT NOT NULL DEFAULT 'default';
+
+ALTER TABLE feeds
+ADD COLUMN skip_hours INTEGER[] NOT NULL DEFAULT '{}';
+
+ALTER TABLE feeds
+ADD COLUMN skip_days INTEGER[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feeds
+DROP COLUMN skip_days;
+
+ALTER TABLE feeds
+DROP COLUMN skip_hours;
+
+ALTER TABLE feeds
+DROP COLUMN fetch_interval_source;
+
+ALTER TABLE feeds
+DROP COLUMN fetch_interval;
+-- +goose StatementEnd